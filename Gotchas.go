@@ -1,3 +1,5 @@
+//go:build ignore
+
 // Some gotchas to be aware of when creating Go routines.
 //
 // 1) Pass-by-reference versus Pass-by-copy can be an issue.
@@ -0,0 +1,1235 @@
+// Simple proof of concept for a heartbeat function.
+//
+// May also be used as a Stop-Loss alert (for instance
+// if a stock quote falls below a specific value) with
+// the -jsonpath, -regexp or -xpath flags, or for load
+// testing (simulating multiple concurrent visitors).
+//
+// The polling itself is done by the heartbeat package
+// (in the top-level heartbeat directory) which may be imported
+// by other programs - this file is just a command line
+// wrapper around heartbeat.Monitor and heartbeat.Pool.
+//
+// USAGE
+//
+//     ./heartbeat watch    [flags]    poll until Ctrl-C
+//     ./heartbeat check    [flags]    fetch once, Nagios plugin style
+//     ./heartbeat load     [flags]    simulate many concurrent visitors
+//     ./heartbeat history  [flags]    show the results kept by 'watch -store'
+//     ./heartbeat validate -config file
+//
+// The subcommand defaults to 'watch'. Targets are given
+// either with one or more -url flags (all sharing the same
+// settings) or with a JSON -config file. Durations are
+// specified Go-style, as in 30s, 2m or 1h30m.
+//
+// The default URL is http://localhost
+//
+// The default polling time is every 5 minutes
+//
+// The default polling timeout is 10 seconds
+// 
+// The default variance (response size or time) is 5 percent
+//
+// When the first fetch completes, the returned length
+// will be saved, and lengths that differ from this by
+// more or less than the specified deviation on later
+// fetches will generate alerts.
+//
+// In a similiar manner, significant deviations from
+// the initial fetch time will also generate an alert.
+//
+// Rather than comparing with the previous baseline, the
+// time and size may be compared with a rolling mean
+// (-baseline mean), a moving average (ewma) or a rolling
+// median (median) of recent fetches, or with fixed limits
+// (-time-range, -size-range). These only alert after a
+// -warmup period, so a slow first fetch does not matter.
+//
+// Fetches that do not complete within the timeout period
+// will generate another type of alert.
+//
+// So that an error page of much the same size is not taken
+// for the real thing, responses may also be checked for an
+// expected -status code, required (-header) or forbidden
+// (-no-header) headers, and body text (-contains and
+// -not-contains) or regexps (-match and -not-match). JSON
+// health checks may be tested with -json-assert, as in:
+//
+//     ./heartbeat -url http://localhost/health -json-assert '$.status == "ok"' \
+//         -json-assert '$.db.latency_ms < 100' -json-assert '$.errors.length() == 0'
+//
+// Every assertion that fails raises its own alert, showing
+// the value actually found.
+//
+// An alert is only raised once a target has failed -failures
+// fetches in a row, is not repeated while the target stays
+// down, and is followed by a single 'RECOVERED' notice after
+// -successes good fetches. Targets that keep changing state
+// (see -flap-window) are reported as flapping and their
+// alerts suppressed until they settle down.
+//
+// With -store (or a "store" in the -config file) the result
+// of every fetch is kept on disk, one file per day, for the
+// history command to show:
+//
+//     ./heartbeat history -target X -since 24h
+//     ./heartbeat history -since "2017-04-20 14:00" -until "2017-04-20 15:00" -format csv
+//
+// With -listen (or "listen" in the -config file) the latest
+// results are also served as a status page (which refreshes
+// itself) and for Prometheus to scrape, as in:
+//
+//     ./heartbeat -url http://localhost -listen :9100
+//     open http://localhost:9100/
+//     curl http://localhost:9100/metrics
+//
+// Alerts are printed, and may also be sent elsewhere: as
+// JSON to a -webhook, by email (-mail-to), or to a local
+// command (-exec) which gets the details in HEARTBEAT_*
+// environment variables. A -config file may name its own
+// notifiers and choose which are used for each target.
+//
+// The -exec command is run directly, not by a shell, so
+// its arguments are given one at a time with -exec-arg:
+//
+//     ./heartbeat -url http://localhost -exec logger \
+//         -exec-arg -t -exec-arg heartbeat
+//
+// The first DNS lookup is ignored for variance purposes.
+// This is because the very first lookup may well take a
+// significant amount of time compared to subsequent
+// lookups when this value will be served from cache.
+//
+// [With HTTP/2 the DNS lookup can be a significant part
+//    of the overall round-trip time.]
+//
+// However any subsequent DNS lookups as a result of HTTP
+// redirects will be considered for variance purposes.
+//
+// The TLS version, cipher suite and certificate chain of
+// every HTTPS fetch are recorded (-v shows them). Alerts are
+// raised -cert-expiry days before any certificate in the chain
+// expires, when the certificate does not match the host name,
+// and when the TLS version falls below -tls-min (or below the
+// version negotiated before).
+//
+// Each target is requested with a GET unless -method says
+// otherwise; -request-header, -query, -body (or -body-file),
+// -user (Basic), -bearer and -user-agent complete the request,
+// as in:
+//
+//     ./heartbeat check -url https://localhost/api -method POST \
+//         -request-header 'Content-Type: application/json' -body '{"q": 1}'
+//
+// An invalid request is rejected before polling starts.
+//
+// A multi-step check - log in, fetch a token, call the API,
+// log out - is described by a target's "scenario" in a -config
+// file (see heartbeat.Scenario). Values captured from one
+// step (a JSON field, header, cookie or regexp group) are
+// used by later steps as ${name}, and the steps share a
+// cookie jar. The scenario is reported as one check, with
+// the timing of each step, and alerts name the step that
+// failed.
+//
+// Private CAs are trusted with -ca, and -cert (and -key)
+// present a client certificate to servers that require
+// mutual TLS. -server-name overrides the name sent (SNI)
+// and verified. With -pin, a certificate in the chain must
+// match one of the pins given, or the connection is closed
+// before any request is sent - the alert shows the pin of
+// the server's key, as in:
+//
+//     ./heartbeat check -url https://test.local -insecure -pin sha256/...
+//
+// -insecure skips verification (for self-signed test servers)
+// so that pinning is the only check made.
+//
+// Every hop of a redirect chain is recorded (with -v, each
+// is shown with its timing and DNS lookups). Up to 10
+// redirects are followed (see -max-redirects and -no-follow),
+// and a chain that differs from the one before - or from the
+// -redirects expected - raises an alert, as in:
+//
+//     ./heartbeat -url http://localhost -redirects https://localhost/
+//
+// With -format json, 'watch' prints one JSON object per line
+// for each check started and completed (with its timings),
+// alert raised or cleared, and error - with RFC 3339 times
+// and stable field names, for log pipelines rather than
+// people. Other messages then go to stderr.
+//
+// Every fetch is timed phase by phase: DNS lookup, TCP
+// connect, TLS handshake, request write, server processing
+// (wait) and body transfer. The check command reports each
+// of these as performance data, and -phase-w / -phase-c (or
+// phase_warning / phase_critical in a -config file) set
+// limits on any of them, which raise alerts when exceeded.
+//
+// REQUIRES Go 1.24 (for http.Protocols; see go.mod)
+//
+// ---------------------------------------------------
+//
+// TEST PLAN
+//
+// 1) Verify command line arguments are defaulted correctly
+//
+// 2) 'host unreachable'
+//
+//    Make sure web server is down
+//
+//     ./heartbeat
+//     ./heartbeat -url https://localhost
+//
+//    Both tests should warn 'host unreachable' (and keep polling)
+//
+//    Start the web server, verify the 'RECOVERED' message
+//
+// 3) 'host reachable' / x509 self-signed certificate error
+//
+//    Make sure web server is running (with HTTPS configured)
+//
+//     ./heartbeat
+//     ./heartbeat -url https://localhost
+//
+//    First test should succeed (Ctrl-C to kill)
+//    Second test should fail (x509 error due to self-signed certificate)
+//
+// 4) Length Variance
+//
+//     ./heartbeat -url http://localhost/test2.php -interval 1m -timeout 1s -variance 1 -v
+//
+//     Wait until the variance range is displayed, 
+//       then add or subtract characters from the
+//       test2.php web page (or any other web page,
+//       as appropriate) in order to trigger a
+//       length variance.
+//
+//     Wait a minute or so, verify Length variance warning message
+//
+//     Wait another minute, verify Length variance warning message resets
+//
+// 5) Time Variance
+//
+//     Set sleep time in 'timer.php' to 5 (seconds)
+//
+//         [timer.php should take 5 seconds (plus overhead) to run]
+//
+//     ./heartbeat -url http://localhost/timer.php -interval 1m -timeout 6s -variance 1
+//
+//         [timeout is set to 6 seconds to allow timer.php to complete]
+//
+//     After first response, set sleep time in 'timer.php' to 4 (seconds)
+//
+//     Wait a minute or so, verify Time variance warning message
+//
+//     Wait another minute, verify Time variance warning message resets
+//
+// 6) Timeout
+//
+//     Set sleep time in 'timer.php' to 1 (seconds)
+//
+//         [timer.php should take 1 second (plus overhead) to run]
+//
+//     ./heartbeat -url http://localhost/timer.php -interval 1m -timeout 2s -variance 1
+//
+//         [timeout is set to 2 seconds to allow timer.php to complete]
+//
+//     After first response, set sleep time in 'timer.php' to 2 (seconds)
+//
+//     Wait a minute or so, verify Timeout warning message
+//
+//     Wait another minute, verify Timeout warning message does not repeat
+//
+//     Set sleep time back to 1, verify the 'RECOVERED' message
+//
+//     Repeat with -failures 3 -successes 2, verify that the
+//     warning waits for 3 timeouts and the recovery for 2
+//     good fetches
+//
+// 7) Ignore first DNS lookup
+//
+//     ./heartbeat -url https://oracle.com -interval 5m -v
+//
+//         [polling time is set to 5 minutes to allow connections to expire]
+//
+//     Wait at least 10 minutes, verify that first DNS lookup is correctly ignored
+//
+//     [Update: DNS times seem to fluctuate wildly, 
+//              perhaps best to use 'nslookup' to
+//              determine FQDN and skip DNS completely.]
+//
+//     ./heartbeat -url https://137.254.120.50 -interval 5m -v
+//
+//     [Update: Even using a FQDN seems to trigger DNS lookups.]
+//
+// ---------------------------------------------------
+//
+// @ Martin Ramshaw, April 2017 (mramshaw@alumni.concordia.ca)
+
+package main
+
+import (
+    "context"
+    "encoding/csv"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "net"
+    "net/http"
+    "net/url"
+    "os"
+    "runtime"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/mramshaw/Golang/heartbeat"
+)
+
+const (
+    version         = "2.0"
+)
+
+var (
+    verbose   bool
+    console   io.Writer = os.Stdout     // for messages to people (stderr with -format json)
+)
+
+// ===============================================================
+
+// stringList is a flag.Value that collects repeated flags, such as -url.
+type stringList []string
+
+func (u *stringList) String() string {
+
+    return strings.Join(*u, ",")
+}
+
+func (u *stringList) Set(s string) error {
+
+    *u = append(*u, s)
+    return nil
+}
+
+// optFloat is a flag.Value for an optional number.
+type optFloat struct {
+    val *float64
+}
+
+func (f *optFloat) String() string {
+
+    if f.val == nil {
+        return ""
+    }
+    return strconv.FormatFloat(*f.val, 'g', -1, 64)
+}
+
+func (f *optFloat) Set(s string) error {
+
+    v, err := strconv.ParseFloat(s, 64)
+    if err != nil {
+        return err
+    }
+    f.val = &v
+    return nil
+}
+
+// phaseLimits is a flag.Value that collects repeated phase=duration flags.
+type phaseLimits map[string]time.Duration
+
+func (p phaseLimits) String() string {
+
+    var limits []string
+    for name, d := range p {
+        limits = append(limits, name + "=" + d.String())
+    }
+    sort.Strings(limits)
+    return strings.Join(limits, ",")
+}
+
+func (p phaseLimits) Set(s string) error {
+
+    i := strings.Index(s, "=")
+    if i < 0 {
+        return fmt.Errorf("expected phase=duration, as in ttfb=500ms")
+    }
+    name := s[:i]
+    known := false
+    for _, n := range heartbeat.PhaseNames {
+        known = known || n == name
+    }
+    if !known {
+        return fmt.Errorf("unknown phase '%s' (use %s)", name, strings.Join(heartbeat.PhaseNames, ", "))
+    }
+    d, err := time.ParseDuration(s[i + 1:])
+    if err != nil {
+        return err
+    }
+    p[name] = d
+    return nil
+}
+
+// options holds the flags shared by the subcommands.
+type options struct {
+    flags       *flag.FlagSet
+    config      string
+    loaded      *heartbeat.Config   // the -config file, once loaded by pool
+    urls        stringList
+    interval    time.Duration
+    timeout     time.Duration
+    variance    int
+    concurrency int
+    protocol    string
+    method      string
+    reqHeaders  stringList
+    query       stringList
+    body        string
+    bodyFile    string
+    user        string
+    bearer      string
+    userAgent   string
+    webhook     string
+    command     string
+    commandArgs stringList
+    mail        heartbeat.EmailNotifier
+    mailTo      string
+    policy      heartbeat.AlertPolicy
+    redirects   heartbeat.RedirectPolicy
+    tls         heartbeat.TLSPolicy
+    phaseWarn   phaseLimits
+    phaseCrit   phaseLimits
+    expect      string
+    baseline    string
+    timeBase    string
+    sizeBase    string
+    timeRange   string
+    sizeRange   string
+    stats       heartbeat.BaselineConfig    // shared by the time and size baselines
+    value       heartbeat.ValueCheck
+    above       optFloat
+    below       optFloat
+    status      string
+    headers     stringList
+    noHeaders   stringList
+    assert      heartbeat.Assertions
+    usageCode   int             // exit code for invalid usage
+    targetFlags map[string]bool // the flags describing targets, which -config replaces
+}
+
+func newOptions(cmd string) *options {
+
+    o := &options{flags: flag.NewFlagSet(cmd, flag.ContinueOnError), usageCode: 2,
+                  phaseWarn: phaseLimits{}, phaseCrit: phaseLimits{}}
+    f := o.flags
+    f.StringVar(&o.config,      "config",      "",                           "JSON configuration `file` describing the targets")
+    f.Var(&o.urls,              "url",                                       "`URL` to heartbeat (may be repeated; default " + heartbeat.DefaultURL + ")")
+    f.DurationVar(&o.interval,  "interval",    heartbeat.DefaultInterval,    "polling interval")
+    f.DurationVar(&o.timeout,   "timeout",     heartbeat.DefaultTimeout,     "fetch timeout")
+    f.IntVar(&o.variance,       "variance",    heartbeat.DefaultVariance,    "allowable response size or time variance (`percent`)")
+    f.IntVar(&o.concurrency,    "concurrency", heartbeat.DefaultConcurrency, "maximum simultaneous fetches")
+    f.StringVar(&o.protocol,    "protocol",    "",                           "HTTP `version` to use and expect: HTTP/1.0, HTTP/1.1, h2 or h2c (default any)")
+    f.StringVar(&o.method,      "method",      "",                           "HTTP `method` of the request (default GET)")
+    f.Var(&o.reqHeaders,        "request-header",                            "send this request header, as in `'Name: value'` (may be repeated)")
+    f.Var(&o.query,             "query",                                     "add this `name=value` to the URL's query string (may be repeated)")
+    f.StringVar(&o.body,        "body",        "",                           "send this `text` as the request body")
+    f.StringVar(&o.bodyFile,    "body-file",   "",                           "send the contents of this `file` as the request body")
+    f.StringVar(&o.user,        "user",        "",                           "Basic authentication as `user:password`")
+    f.StringVar(&o.bearer,      "bearer",      "",                           "Bearer authentication with this `token`")
+    f.StringVar(&o.userAgent,   "user-agent",  "",                           "send this `User-Agent` header")
+    f.StringVar(&o.value.JSONPath, "jsonpath", "",                           "extract a value from the (JSON) response at this `path`, as in $.quote.price")
+    f.StringVar(&o.value.Regexp,   "regexp",   "",                           "extract a value from the response with this `regexp` (first capture group)")
+    f.StringVar(&o.value.XPath,    "xpath",    "",                           "extract a value from the (XML or HTML) response at this `path`, as in //span[@id='price']")
+    f.Var(&o.above,                "above",                                  "alert if the extracted value rises above this `number`")
+    f.Var(&o.below,                "below",                                  "alert if the extracted value falls below this `number`")
+    f.Float64Var(&o.value.Change,  "change",   0,                            "alert if the extracted value moves more than this `percent`")
+    f.StringVar(&o.status,         "status",   "",                           "alert unless the status code is one of these comma-separated `codes`, as in 200,204")
+    f.Var(&o.headers,              "header",                                 "alert unless the response has this header, as in `'Name: value'` (value optional; may be repeated)")
+    f.Var(&o.noHeaders,            "no-header",                              "alert if the response has this header, as in `'Name: value'` (value optional; may be repeated)")
+    f.Var((*stringList)(&o.assert.Contains),    "contains",     "alert unless the response body contains this `text` (may be repeated)")
+    f.Var((*stringList)(&o.assert.NotContains), "not-contains", "alert if the response body contains this `text` (may be repeated)")
+    f.Var((*stringList)(&o.assert.Matches),     "match",        "alert unless the response body matches this `regexp` (may be repeated)")
+    f.Var((*stringList)(&o.assert.NotMatches),  "not-match",    "alert if the response body matches this `regexp` (may be repeated)")
+    f.Var((*stringList)(&o.assert.JSON),        "json-assert",  "alert unless the (JSON) response passes this `assertion`, as in '$.db.latency_ms < 100' (may be repeated)")
+    f.IntVar(&o.policy.Failures,    "failures",     1, "consecutive failed fetches before alerting")
+    f.IntVar(&o.policy.Successes,   "successes",    1, "consecutive good fetches before recovery")
+    f.IntVar(&o.policy.FlapWindow,  "flap-window",  0, "number of recent fetches checked for flapping (default none)")
+    f.IntVar(&o.policy.FlapChanges, "flap-changes", 0, "status changes within the flap window that suppress alerts")
+    f.IntVar(&o.redirects.Max,      "max-redirects", 0,    "most redirects to follow (default 10)")
+    f.BoolVar(&o.redirects.NoFollow, "no-follow",    false, "do not follow redirects")
+    f.StringVar(&o.expect,          "redirects",     "",    "alert unless redirected to these comma-separated `URLs` in turn, or none (default alert on any change)")
+    f.IntVar(&o.tls.ExpiryDays,     "cert-expiry",   0,     "alert this many `days` before any certificate expires (default 14)")
+    f.StringVar(&o.tls.MinVersion,  "tls-min",       "",    "alert if the TLS `version` negotiated is below this: 1.0, 1.1, 1.2 or 1.3")
+    f.Var((*stringList)(&o.tls.CAFiles), "ca",        "also trust the CAs in this PEM `file` (may be repeated)")
+    f.StringVar(&o.tls.Cert,        "cert",          "",    "present the client certificate in this PEM `file` (mutual TLS)")
+    f.StringVar(&o.tls.Key,         "key",           "",    "PEM `file` of the client certificate's private key (default in -cert)")
+    f.StringVar(&o.tls.ServerName,  "server-name",   "",    "send and verify this TLS server `name` (SNI) instead of the URL's host")
+    f.Var((*stringList)(&o.tls.Pins), "pin",         "only connect if a certificate in the chain matches this `pin`: sha256/<base64 public key hash> or sha256:<hex fingerprint> (may be repeated)")
+    f.BoolVar(&o.tls.Insecure,      "insecure",      false, "do not verify certificates (for self-signed test servers)")
+    f.Var(o.phaseWarn,              "phase-w",             "`phase=duration` above which a phase is WARNING, as in tls=200ms (may be repeated)")
+    f.Var(o.phaseCrit,              "phase-c",             "`phase=duration` above which a phase is CRITICAL, as in wait=1s (may be repeated)")
+    f.StringVar(&o.baseline,        "baseline",      "", "`strategy` for the time and size baselines: variance, mean, ewma, median or fixed (default variance)")
+    f.StringVar(&o.timeBase,        "time-baseline", "", "`strategy` for the time baseline (overrides -baseline)")
+    f.StringVar(&o.sizeBase,        "size-baseline", "", "`strategy` for the size baseline (overrides -baseline)")
+    f.IntVar(&o.stats.Window,       "window",        0,  "number of recent fetches in a mean or median baseline (default 20)")
+    f.IntVar(&o.stats.WarmUp,       "warmup",        0,  "number of fetches before a statistical baseline alerts (default 5)")
+    f.Float64Var(&o.stats.Deviations, "deviations",  0,  "allowable deviations from a statistical baseline (default 3)")
+    f.Float64Var(&o.stats.Alpha,    "alpha",         0,  "smoothing factor of an ewma baseline, between 0 and 1 (default 0.3)")
+    f.StringVar(&o.timeRange,       "time-range",    "", "fixed time baseline, as in `100ms:2s` (either limit may be omitted)")
+    f.StringVar(&o.sizeRange,       "size-range",    "", "fixed size baseline in bytes, as in `1000:5000` (either limit may be omitted)")
+    f.StringVar(&o.webhook,     "webhook",     "",                           "POST alerts as JSON to this `URL`")
+    f.StringVar(&o.command,     "exec",        "",                           "run this `command` for each alert (details in HEARTBEAT_* variables)")
+    f.Var(&o.commandArgs,       "exec-arg",                                  "pass this `argument` to the -exec command (may be repeated)")
+    f.StringVar(&o.mailTo,      "mail-to",     "",                           "email alerts to these comma-separated `addresses`")
+    f.StringVar(&o.mail.From,   "mail-from",   "heartbeat@localhost",        "sender `address` of alert emails")
+    f.StringVar(&o.mail.Server, "mail-server", "localhost:25",               "SMTP server (`host:port`) for alert emails")
+    o.targetFlags = map[string]bool{}
+    f.VisitAll(func(fl *flag.Flag) {
+        o.targetFlags[fl.Name] = fl.Name != "config"
+    })
+    f.BoolVar(&verbose,         "v",           false,                        "verbose mode")
+    f.Usage = func() {
+        fmt.Fprintf(os.Stderr, "Usage is:\n\n    ./heartbeat %s [flags]\n\n", cmd)
+        f.PrintDefaults()
+        fmt.Fprintf(os.Stderr, "\nDurations are specified as in 30s, 2m or 1h30m.\n")
+    }
+    return o
+}
+
+// Parses the flags, then builds a Pool holding the requested targets -
+// either from the configuration file or from the -url flags.
+func (o *options) pool(args []string) *heartbeat.Pool {
+
+    o.parse(args)
+    if o.flags.NArg() > 0 {
+        fmt.Fprintf(os.Stderr, "Unexpected argument: '%s'\n\n", o.flags.Arg(0))
+        o.flags.Usage()
+        os.Exit(o.usageCode)
+    }
+
+    extract := o.value.JSONPath != "" || o.value.Regexp != "" || o.value.XPath != ""
+    assert  := o.status != "" || len(o.headers) > 0 || len(o.noHeaders) > 0 ||
+               len(o.assert.Contains) > 0 || len(o.assert.NotContains) > 0 ||
+               len(o.assert.Matches) > 0 || len(o.assert.NotMatches) > 0 || len(o.assert.JSON) > 0
+    if o.config != "" {
+        // Every setting of the targets comes from the file, so any
+        // target flag given as well would be silently ignored.
+        var ignored []string
+        o.flags.Visit(func(fl *flag.Flag) {
+            if o.targetFlags[fl.Name] {
+                ignored = append(ignored, "-" + fl.Name)
+            }
+        })
+        if len(ignored) > 0 {
+            fmt.Fprintf(os.Stderr, "Specify either -config or -url (and target flags), not both: %s not allowed with -config\n\n",
+                        strings.Join(ignored, ", "))
+            o.flags.Usage()
+            os.Exit(o.usageCode)
+        }
+        config, err := heartbeat.LoadConfig(o.config)
+        if err == nil {
+            var pool *heartbeat.Pool
+            if pool, err = config.Pool(); err == nil {
+                o.loaded = config
+                return pool
+            }
+        }
+        fmt.Fprintf(os.Stderr, "%v\n", err)
+        os.Exit(o.usageCode)
+    }
+
+    protocol, err := heartbeat.ParseProtocol(o.protocol)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%v\n\n", err)
+        os.Exit(o.usageCode)
+    }
+    timeBase, sizeBase, err := o.baselines()
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%v\n\n", err)
+        os.Exit(o.usageCode)
+    }
+    var value *heartbeat.ValueCheck
+    if extract || o.above.val != nil || o.below.val != nil || o.value.Change != 0 {
+        value = &o.value
+        value.Above = o.above.val
+        value.Below = o.below.val
+    }
+    switch o.expect {
+    case "":
+    case "none":
+        o.redirects.Expect = []string{}
+    default:
+        o.redirects.Expect = strings.Split(o.expect, ",")
+    }
+    var assertions *heartbeat.Assertions
+    if assert {
+        if assertions, err = o.assertions(); err != nil {
+            fmt.Fprintf(os.Stderr, "%v\n\n", err)
+            os.Exit(o.usageCode)
+        }
+    }
+    var notifiers []heartbeat.Notifier
+    if o.webhook != "" {
+        notifiers = append(notifiers, &heartbeat.WebhookNotifier{URL: o.webhook})
+    }
+    if o.command != "" {
+        notifiers = append(notifiers, &heartbeat.CommandNotifier{Command: o.command, Args: o.commandArgs})
+    } else if len(o.commandArgs) > 0 {
+        fmt.Fprintf(os.Stderr, "-exec-arg needs -exec\n\n")
+        os.Exit(o.usageCode)
+    }
+    if o.mailTo != "" {
+        o.mail.To = strings.Split(o.mailTo, ",")
+        notifiers = append(notifiers, &o.mail)
+    }
+    if len(o.urls) == 0 {
+        fmt.Fprintf(os.Stderr, "   ... defaulting URL to %s\n\n", heartbeat.DefaultURL)
+        o.urls = stringList{heartbeat.DefaultURL}
+    }
+    pool := heartbeat.NewPool(o.concurrency)
+    for _, url := range o.urls {
+        m := heartbeat.NewMonitor(url)
+        m.Interval     = o.interval
+        m.Timeout      = o.timeout
+        m.Variance     = o.variance
+        m.Value        = value
+        m.Assert       = assertions
+        m.Protocol     = protocol
+        m.Policy       = o.policy
+        m.Redirects    = o.redirects
+        m.TLS          = o.tls
+        m.PhaseWarning  = o.phaseWarn
+        m.PhaseCritical = o.phaseCrit
+        m.TimeBaseline = timeBase
+        m.SizeBaseline = sizeBase
+        m.Notifiers    = notifiers
+        if err := o.setRequest(m); err != nil {
+            fmt.Fprintf(os.Stderr, "%s: %v\n\n", url, err)
+            os.Exit(o.usageCode)
+        }
+        if err := m.Validate(); err != nil {
+            fmt.Fprintf(os.Stderr, "%s: %v\n\n", url, err)
+            os.Exit(o.usageCode)
+        }
+        pool.Add(m)
+    }
+    return pool
+}
+
+// Sets the request of a Monitor from the flags.
+func (o *options) setRequest(m *heartbeat.Monitor) error {
+
+    m.Method    = strings.ToUpper(o.method)
+    m.UserAgent = o.userAgent
+    for _, s := range o.reqHeaders {
+        i := strings.Index(s, ":")
+        if i < 0 {
+            return fmt.Errorf("invalid request header '%s' (use 'Name: value')", s)
+        }
+        if m.Header == nil {
+            m.Header = http.Header{}
+        }
+        m.Header.Add(strings.TrimSpace(s[:i]), strings.TrimSpace(s[i + 1:]))
+    }
+    for _, s := range o.query {
+        i := strings.Index(s, "=")
+        if i < 0 {
+            return fmt.Errorf("invalid query parameter '%s' (use name=value)", s)
+        }
+        if m.Query == nil {
+            m.Query = url.Values{}
+        }
+        m.Query.Add(s[:i], s[i + 1:])
+    }
+    switch {
+    case o.body != "" && o.bodyFile != "":
+        return fmt.Errorf("use either -body or -body-file, not both")
+    case o.body != "":
+        m.Body = []byte(o.body)
+    case o.bodyFile != "":
+        body, err := ioutil.ReadFile(o.bodyFile)
+        if err != nil {
+            return err
+        }
+        m.Body = body
+    }
+    switch {
+    case o.user != "" && o.bearer != "":
+        return fmt.Errorf("use either -user or -bearer, not both")
+    case o.user != "":
+        i := strings.Index(o.user, ":")
+        if i < 0 {
+            return fmt.Errorf("invalid -user '%s' (use user:password)", o.user)
+        }
+        m.Auth = &heartbeat.Auth{Username: o.user[:i], Password: o.user[i + 1:]}
+    case o.bearer != "":
+        m.Auth = &heartbeat.Auth{BearerToken: o.bearer}
+    }
+    return nil
+}
+
+// Builds the response assertions from the flags.
+func (o *options) assertions() (*heartbeat.Assertions, error) {
+
+    a := &o.assert
+    for _, code := range strings.Split(o.status, ",") {
+        if code = strings.TrimSpace(code); code == "" {
+            continue
+        }
+        n, err := strconv.Atoi(code)
+        if err != nil {
+            return nil, fmt.Errorf("invalid status code '%s'", code)
+        }
+        a.Status = append(a.Status, n)
+    }
+    header := func(headers stringList) map[string]string {
+        if len(headers) == 0 {
+            return nil
+        }
+        h := map[string]string{}
+        for _, s := range headers {
+            name, value := s, ""
+            if i := strings.Index(s, ":"); i >= 0 {
+                name, value = s[:i], s[i + 1:]
+            }
+            h[strings.TrimSpace(name)] = strings.TrimSpace(value)
+        }
+        return h
+    }
+    a.Headers   = header(o.headers)
+    a.NoHeaders = header(o.noHeaders)
+    return a, a.Validate()
+}
+
+// Builds the time and size baselines from the flags.
+func (o *options) baselines() (timeBase, sizeBase heartbeat.BaselineConfig, err error) {
+
+    timeBase, sizeBase = o.stats, o.stats
+    timeBase.Strategy = heartbeat.Strategy(o.baseline)
+    sizeBase.Strategy = heartbeat.Strategy(o.baseline)
+    if o.timeBase != "" {
+        timeBase.Strategy = heartbeat.Strategy(o.timeBase)
+    }
+    if o.sizeBase != "" {
+        sizeBase.Strategy = heartbeat.Strategy(o.sizeBase)
+    }
+
+    ms := func(s string) (float64, error) {
+        d, err := time.ParseDuration(s)
+        return float64(d) / float64(time.Millisecond), err
+    }
+    bytes := func(s string) (float64, error) {
+        return strconv.ParseFloat(s, 64)
+    }
+    for _, r := range []struct {
+        flag, val string
+        base      *heartbeat.BaselineConfig
+        parse     func(string) (float64, error)
+    }{
+        {"time-range", o.timeRange, &timeBase, ms},
+        {"size-range", o.sizeRange, &sizeBase, bytes},
+    } {
+        if r.val == "" {
+            continue
+        }
+        lo, hi := r.val, ""
+        if i := strings.Index(r.val, ":"); i >= 0 {
+            lo, hi = r.val[:i], r.val[i + 1:]
+        }
+        if lo != "" {
+            if r.base.Min, err = r.parse(lo); err != nil {
+                return timeBase, sizeBase, fmt.Errorf("invalid -%s '%s': %v", r.flag, r.val, err)
+            }
+        }
+        if hi != "" {
+            if r.base.Max, err = r.parse(hi); err != nil {
+                return timeBase, sizeBase, fmt.Errorf("invalid -%s '%s': %v", r.flag, r.val, err)
+            }
+        }
+        if r.base.Strategy == "" {
+            r.base.Strategy = heartbeat.StrategyFixed
+        }
+    }
+    return timeBase, sizeBase, nil
+}
+
+// Parses the flags, exiting if they are invalid.
+func (o *options) parse(args []string) {
+
+    parseFlags(o.flags, args, o.usageCode)
+}
+
+// Returns the flags of a subcommand that takes no target flags.
+func newFlagSet(cmd string) *flag.FlagSet {
+
+    f := flag.NewFlagSet(cmd, flag.ContinueOnError)
+    f.Usage = func() {
+        fmt.Fprintf(os.Stderr, "Usage is:\n\n    ./heartbeat %s [flags]\n\n", cmd)
+        f.PrintDefaults()
+    }
+    return f
+}
+
+// Parses the flags of a subcommand, exiting with the code if they are invalid.
+func parseFlags(f *flag.FlagSet, args []string, code int) {
+
+    if err := f.Parse(args); err != nil {
+        if err == flag.ErrHelp {
+            os.Exit(0)
+        }
+        os.Exit(code)
+    }
+}
+
+func main() {
+
+    cmd, args := "watch", os.Args[1:]
+    if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+        cmd, args = args[0], args[1:]
+    }
+
+    switch cmd {
+    case "watch":
+        watch(args)
+    case "check":
+        check(args)
+    case "load":
+        load(args)
+    case "history":
+        history(args)
+    case "validate":
+        validate(args)
+    default:
+        fmt.Fprintf(os.Stderr, "Unknown command: '%s'\n\n", cmd)
+        usage()
+        os.Exit(2)
+    }
+}
+
+// Polls every target until Ctrl-C.
+func watch(args []string) {
+
+    var dir, listen, format string
+    var retention time.Duration
+
+    o := newOptions("watch")
+    o.flags.StringVar(&dir,            "store",     "",     "keep every result in this `directory` (see the history command)")
+    o.flags.DurationVar(&retention,    "retention", 0,      "how long to keep results in the store (default forever)")
+    o.flags.StringVar(&listen,         "listen",    "",     "serve a status page, and Prometheus metrics at /metrics, on this `address` (as in :9100)")
+    o.flags.StringVar(&format,         "format",    "text", "output `format`: text, or json for one event per line (other messages go to stderr)")
+    pool := o.pool(args)
+    var events *heartbeat.EventLog
+    switch format {
+    case "text":
+    case "json":
+        console = os.Stderr
+        events  = heartbeat.NewEventLog(os.Stdout)
+    default:
+        fmt.Fprintf(os.Stderr, "Unknown format: '%s'\n", format)
+        os.Exit(o.usageCode)
+    }
+    if o.loaded != nil && listen == "" {
+        listen = o.loaded.Listen
+    }
+    if listen != "" {
+        metrics   := heartbeat.NewMetrics()
+        dashboard := heartbeat.NewDashboard()
+        for _, m := range pool.Monitors() {
+            m.Metrics   = metrics
+            m.Dashboard = dashboard
+            dashboard.Add(m)
+        }
+        ln, err := net.Listen("tcp", listen)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "%v\n", err)
+            os.Exit(o.usageCode)
+        }
+        mux := http.NewServeMux()
+        mux.Handle("/metrics", metrics)
+        mux.Handle("/", dashboard)
+        go func() {
+            exit(http.Serve(ln, mux))
+        }()
+        fmt.Fprintf(console, "Serving the status page at http://%s/ and metrics at /metrics\n", ln.Addr())
+    }
+    if dir != "" {
+        if o.config != "" {
+            fmt.Fprintf(os.Stderr, "Specify the store in the -config file, not with -store\n")
+            os.Exit(o.usageCode)
+        }
+        store, err := heartbeat.OpenStore(dir, retention)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "%v\n", err)
+            os.Exit(o.usageCode)
+        }
+        defer store.Close()
+        for _, m := range pool.Monitors() {
+            m.Store = store
+        }
+    }
+
+    fmt.Fprintf(console, "\n== heartbeat %s (runtime: %s) == Ctrl-C to quit!\n", version, runtime.Version())
+    fmt.Fprintf(console, "\n")
+
+    monitors := pool.Monitors()
+    for _, m := range monitors {
+        switch {
+        case events != nil:
+            m.Events = events
+        case len(monitors) > 1:
+            m.OnResult = reportTarget
+        default:
+            m.OnResult = report
+        }
+        if verbose {
+            m.Verbose = console
+        }
+        fmt.Fprintf(console, "Polling '%s' every %v with a %v timeout +/- %v percent variance\n", m.URL, m.Interval, m.Timeout, m.Variance)
+    }
+
+    exit(pool.Run(context.Background()))		// Infinite loop, Ctrl-C to kill
+}
+
+// Fetches every target once (or -count times) and prints a Nagios
+// plugin style status line with performance data. The exit code is
+// 0, 1, 2 or 3 for OK, WARNING, CRITICAL or UNKNOWN.
+func check(args []string) {
+
+    var th heartbeat.Thresholds
+    var count int
+
+    o := newOptions("check")
+    o.usageCode = int(heartbeat.StatusUnknown)
+    o.flags.DurationVar(&th.Warning,  "w",         0, "response time above which status is WARNING")
+    o.flags.DurationVar(&th.Critical, "c",         0, "response time above which status is CRITICAL")
+    o.flags.Int64Var(&th.MinBytes,    "min-bytes", 0, "response size below which status is WARNING")
+    o.flags.Int64Var(&th.MaxBytes,    "max-bytes", 0, "response size above which status is WARNING")
+    o.flags.IntVar(&count,            "count",     1, "number of fetches per target (after the first, size and time variances apply)")
+    pool := o.pool(args)
+    if count < 1 {
+        fmt.Printf("HEARTBEAT UNKNOWN - invalid count: %d\n", count)
+        os.Exit(int(heartbeat.StatusUnknown))
+    }
+
+    ctx := context.Background()
+    monitors := pool.Monitors()
+    worst := heartbeat.StatusOK
+    var problems, summaries, perfdata []string
+    for _, m := range monitors {
+        if verbose {
+            m.Verbose = os.Stderr       // stdout is reserved for the status line
+        }
+
+        // The phase limits are the target's own (from -phase-w and
+        // -phase-c, or the -config file).
+        th.PhaseWarning, th.PhaseCritical = m.PhaseWarning, m.PhaseCritical
+
+        // Keep the worst fetch, reporting the last one if all are equal.
+        status, summary := heartbeat.StatusOK, ""
+        var last *heartbeat.Result
+        for i := 0; i < count; i++ {
+            res, err := m.CheckOnce(ctx)
+            s, desc := th.Evaluate(res, err)
+            if i == 0 || s >= status {
+                status, summary = s, desc
+            }
+            if res != nil && err == nil {
+                last = res
+            }
+        }
+
+        label := ""
+        if len(monitors) > 1 {
+            label = m.Name
+            summary = m.Name + ": " + summary
+        }
+        if status > worst {
+            worst = status
+        }
+        if status != heartbeat.StatusOK {
+            problems = append(problems, summary)
+        }
+        summaries = append(summaries, summary)
+        if last != nil {
+            perfdata = append(perfdata, th.Perfdata(label, last))
+        }
+    }
+
+    if worst != heartbeat.StatusOK {
+        summaries = problems
+    }
+    line := fmt.Sprintf("HEARTBEAT %s - %s", worst, strings.Join(summaries, ", "))
+    if len(perfdata) > 0 {
+        line += " | " + strings.Join(perfdata, " ")
+    }
+    fmt.Println(line)
+    os.Exit(int(worst))
+}
+
+// Load tests a single target with a number of concurrent visitors,
+// then prints a summary.
+func load(args []string) {
+
+    lt := &heartbeat.LoadTest{}
+    o := newOptions("load")
+    o.flags.IntVar(&lt.Users,           "users",    10, "number of concurrent virtual visitors")
+    o.flags.DurationVar(&lt.Duration,   "duration", 0,  "how long to run the test")
+    o.flags.IntVar(&lt.Requests,        "requests", 0,  "total number of requests to make")
+    o.flags.Float64Var(&lt.Rate,        "rate",     0,  "requests per `second` across all users (default as fast as possible)")
+    pool := o.pool(args)
+
+    monitors := pool.Monitors()
+    if len(monitors) != 1 {
+        fmt.Fprintf(os.Stderr, "Load testing needs exactly one target, not %d\n", len(monitors))
+        os.Exit(2)
+    }
+    if lt.Duration <= 0 && lt.Requests <= 0 {
+        lt.Duration = 10 * time.Second
+    }
+    lt.Monitor = monitors[0]
+
+    fmt.Printf("\n== heartbeat %s (runtime: %s) == load testing '%s' with %d users\n\n", version, runtime.Version(), lt.Monitor.URL, lt.Users)
+
+    rep, err := lt.Run(context.Background())
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%v\n", err)
+        os.Exit(2)
+    }
+
+    fmt.Printf("Requests:    %d in %v (%.1f per second)\n", rep.Requests, rep.Elapsed, rep.Throughput())
+    fmt.Printf("Failures:    %d\n", rep.Failures)
+    var causes []string
+    for cause := range rep.Errors {
+        causes = append(causes, cause)
+    }
+    sort.Strings(causes)
+    for _, cause := range causes {
+        fmt.Printf("    %-12s %d\n", cause, rep.Errors[cause])
+    }
+    fmt.Printf("Transferred: %d bytes\n", rep.Bytes)
+    fmt.Printf("\n")
+    fmt.Printf("%-8s %10s %10s %10s %10s %10s\n", "phase", "mean", "p50", "p90", "p99", "max")
+    ms := func(d time.Duration) string {
+        return fmt.Sprintf("%.2fms", float64(d) / float64(time.Millisecond))
+    }
+    for _, p := range rep.Phases {
+        fmt.Printf("%-8s %10s %10s %10s %10s %10s\n", p.Name, ms(p.Mean), ms(p.P50), ms(p.P90), ms(p.P99), ms(p.Max))
+    }
+    if rep.Failures > 0 {
+        os.Exit(1)
+    }
+}
+
+// Prints (or exports) the stored results of a target.
+func history(args []string) {
+
+    var file, dir, target, since, until, format string
+
+    f := newFlagSet("history")
+    f.StringVar(&file,   "config", "",    "JSON configuration `file` whose store to read")
+    f.StringVar(&dir,    "store",  "",    "`directory` holding the results (default the -config store, or " + heartbeat.DefaultStoreDir + ")")
+    f.StringVar(&target, "target", "",    "`name` or URL of the target (default all targets)")
+    f.StringVar(&since,  "since",  "24h", "start of the period, as a `duration` ago (24h) or a time (2006-01-02 15:04)")
+    f.StringVar(&until,  "until",  "",    "end of the period, as a `duration` ago or a time (default now)")
+    f.StringVar(&format, "format", "text", "output `format`: text, csv or json")
+    parseFlags(f, args, 2)
+    if f.NArg() > 0 {
+        fmt.Fprintf(os.Stderr, "Unexpected argument: '%s'\n\n", f.Arg(0))
+        f.Usage()
+        os.Exit(2)
+    }
+
+    if dir == "" && file != "" {
+        config, err := heartbeat.LoadConfig(file)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "%v\n", err)
+            os.Exit(2)
+        }
+        if config.Store == nil {
+            fmt.Fprintf(os.Stderr, "%s: no store\n", file)
+            os.Exit(2)
+        }
+        dir = config.Store.Dir
+    }
+    if dir == "" {
+        dir = heartbeat.DefaultStoreDir
+    }
+    from, err := parseTime(since)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "invalid -since '%s': %v\n", since, err)
+        os.Exit(2)
+    }
+    var to time.Time
+    if until != "" {
+        if to, err = parseTime(until); err != nil {
+            fmt.Fprintf(os.Stderr, "invalid -until '%s': %v\n", until, err)
+            os.Exit(2)
+        }
+    }
+    if _, err := os.Stat(dir); err != nil {
+        fmt.Fprintf(os.Stderr, "%v\n", err)
+        os.Exit(1)
+    }
+
+    store := &heartbeat.Store{Dir: dir}
+    records, err := store.Query(target, from, to)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%v\n", err)
+        os.Exit(1)
+    }
+
+    switch format {
+    case "json":
+        enc := json.NewEncoder(os.Stdout)
+        for _, r := range records {
+            enc.Encode(r)
+        }
+    case "csv":
+        w := csv.NewWriter(os.Stdout)
+        header := []string{"time", "target", "url", "state", "status_code", "bytes"}
+        for _, name := range heartbeat.PhaseNames {
+            header = append(header, name + "_ms")
+        }
+        w.Write(append(header, "error", "alerts"))
+        for _, r := range records {
+            row := []string{r.Time.Format(time.RFC3339Nano), r.Target, r.URL, r.State,
+                            strconv.Itoa(r.StatusCode), strconv.FormatInt(r.Bytes, 10)}
+            for _, name := range heartbeat.PhaseNames {
+                row = append(row, strconv.FormatFloat(r.Phases[name], 'f', 3, 64))
+            }
+            var alerts []string
+            for _, a := range r.Alerts {
+                alerts = append(alerts, string(a))
+            }
+            w.Write(append(row, string(r.Category), strings.Join(alerts, " ")))
+        }
+        w.Flush()
+    case "text":
+        if len(records) == 0 {
+            fmt.Printf("No results\n")
+            return
+        }
+        width := 0                              // of the target column, if any
+        if target == "" {
+            for _, r := range records {
+                if len(r.Target) > width {
+                    width = len(r.Target)
+                }
+            }
+            fmt.Printf("%-*s  ", width, "target")
+        }
+        fmt.Printf("%-19s  %-8s  %4s  %8s", "time", "state", "code", "bytes")
+        for _, name := range heartbeat.PhaseNames {
+            fmt.Printf("  %8s", name)
+        }
+        fmt.Printf("  %s\n", "error/alerts")
+        for _, r := range records {
+            if target == "" {
+                fmt.Printf("%-*s  ", width, r.Target)
+            }
+            fmt.Printf("%-19s  %-8s  %4d  %8d", r.Time.Local().Format("2006-01-02 15:04:05"), r.State, r.StatusCode, r.Bytes)
+            for _, name := range heartbeat.PhaseNames {
+                fmt.Printf("  %8.1f", r.Phases[name])
+            }
+            notes := []string{string(r.Category)}
+            for _, a := range r.Alerts {
+                notes = append(notes, string(a))
+            }
+            if note := strings.TrimSpace(strings.Join(notes, " ")); note != "" {
+                fmt.Printf("  %s", note)
+            }
+            fmt.Printf("\n")
+        }
+    default:
+        fmt.Fprintf(os.Stderr, "Unknown format: '%s' (use text, csv or json)\n", format)
+        os.Exit(2)
+    }
+}
+
+// Parses a time given as a duration ago, or as a (local) date and time.
+func parseTime(s string) (time.Time, error) {
+
+    if d, err := time.ParseDuration(s); err == nil {
+        return time.Now().Add(-d), nil
+    }
+    if t, err := time.Parse(time.RFC3339, s); err == nil {
+        return t, nil
+    }
+    for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"} {
+        if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+            return t, nil
+        }
+    }
+    return time.Time{}, fmt.Errorf("expected a duration (24h) or a time (2006-01-02 15:04)")
+}
+
+// Validates a configuration file without fetching anything.
+func validate(args []string) {
+
+    var file string
+
+    f := newFlagSet("validate")
+    f.StringVar(&file, "config", "", "JSON configuration `file` to check")
+    parseFlags(f, args, 2)
+    if file == "" || f.NArg() > 0 {
+        f.Usage()
+        os.Exit(2)
+    }
+
+    config, err := heartbeat.LoadConfig(file)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%v\n", err)
+        os.Exit(1)
+    }
+    fmt.Printf("%s: OK, %d targets\n", file, len(config.Targets))
+}
+
+// Reports why polling stopped, then exits.
+func exit(err error) {
+
+    fmt.Fprintf(console, "%v\n", err)
+    os.Exit(-1)
+}
+
+// Prints any alerts raised by a fetch.
+func report(res *heartbeat.Result) {
+
+    printResult("", res)
+}
+
+// Prints any alerts raised by a fetch, prefixed by the target name.
+func reportTarget(res *heartbeat.Result) {
+
+    printResult(res.Name + " ", res)
+}
+
+func printResult(prefix string, res *heartbeat.Result) {
+
+    for _, a := range res.Alerts {
+        fmt.Printf("%s %sWARNING WARNING %s\n", a.Time, prefix, a.Message)
+    }
+    for _, n := range res.Notices {
+        fmt.Printf("%s %s%s\n", n.Time, prefix, n.Message)
+    }
+    if res.Err != nil && len(res.Alerts) == 0 && len(res.Suppressed) == 0 {
+        fmt.Printf("%sError on response:\n%v\n", prefix, res.Err)
+    }
+}
+
+func usage() {
+
+    fmt.Fprintf(os.Stderr, "Usage is:\n")
+    fmt.Fprintf(os.Stderr, "\n")
+    fmt.Fprintf(os.Stderr, "    ./heartbeat watch    [flags]    poll until Ctrl-C (the default)\n")
+    fmt.Fprintf(os.Stderr, "    ./heartbeat check    [flags]    fetch once, Nagios plugin style\n")
+    fmt.Fprintf(os.Stderr, "    ./heartbeat load     [flags]    simulate many concurrent visitors\n")
+    fmt.Fprintf(os.Stderr, "    ./heartbeat history  [flags]    show the results kept by 'watch -store'\n")
+    fmt.Fprintf(os.Stderr, "    ./heartbeat validate -config file\n")
+    fmt.Fprintf(os.Stderr, "\n")
+    fmt.Fprintf(os.Stderr, "Use './heartbeat command -h' for the flags of each command.\n")
+    fmt.Fprintf(os.Stderr, "\n")
+}
@@ -0,0 +1,4 @@
+module github.com/mramshaw/Golang
+
+// http.Protocols (heartbeat/protocol.go) needs Go 1.24
+go 1.24
@@ -0,0 +1,208 @@
+package heartbeat
+
+import (
+    "bytes"
+    "fmt"
+    "net/http"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+// Assertions describe what a healthy response looks like, beyond its
+// size and timing - so that (for instance) an error page of much the
+// same length as the real one is noticed. Every assertion that fails
+// raises its own alert. Empty fields are not checked.
+//
+// Header values are matched as substrings (so "application/json"
+// matches "application/json; charset=utf-8"); an empty value only
+// requires (or forbids) the header itself. Header names are not
+// case sensitive.
+//
+// JSON assertions test the values found in a JSON body, as in
+// '$.status == "ok"', '$.db.latency_ms < 100', '$.items.length() > 0',
+// '$.error !exists' or '$.version type string'.
+type Assertions struct {
+    Status      []int             `json:"status"`              // acceptable status codes
+    Headers     map[string]string `json:"headers"`             // required headers (and values)
+    NoHeaders   map[string]string `json:"forbidden_headers"`   // forbidden headers (or values)
+    Contains    []string          `json:"contains"`            // strings the body must contain
+    NotContains []string          `json:"not_contains"`        // strings the body must not contain
+    Matches     []string          `json:"matches"`             // regexps the body must match
+    NotMatches  []string          `json:"not_matches"`         // regexps the body must not match
+    JSON        []string          `json:"json"`                // JSON path assertions the body must pass
+
+    matches     []*regexp.Regexp
+    notMatches  []*regexp.Regexp
+    json        []*jsonAssertion
+    prepared    bool                // by Validate
+}
+
+// Validate checks (and compiles) the assertions.
+func (a *Assertions) Validate() error {
+
+    for _, code := range a.Status {
+        if code < 100 || code > 599 {
+            return fmt.Errorf("invalid status code %d", code)
+        }
+    }
+    for name := range a.Headers {
+        if strings.TrimSpace(name) == "" {
+            return fmt.Errorf("header name must not be empty")
+        }
+    }
+    for name := range a.NoHeaders {
+        if strings.TrimSpace(name) == "" {
+            return fmt.Errorf("header name must not be empty")
+        }
+    }
+    compile := func(exprs []string) ([]*regexp.Regexp, error) {
+        var res []*regexp.Regexp
+        for _, expr := range exprs {
+            re, err := regexp.Compile(expr)
+            if err != nil {
+                return nil, fmt.Errorf("invalid regexp '%s': %v", expr, err)
+            }
+            res = append(res, re)
+        }
+        return res, nil
+    }
+    var err error
+    if a.matches, err = compile(a.Matches); err != nil {
+        return err
+    }
+    if a.notMatches, err = compile(a.NotMatches); err != nil {
+        return err
+    }
+    a.json = nil
+    for _, expr := range a.JSON {
+        j, err := parseJSONAssertion(expr)
+        if err != nil {
+            return err
+        }
+        a.json = append(a.json, j)
+    }
+    a.prepared = true
+    return nil
+}
+
+// needsBody reports whether any of the assertions look at the body.
+func (a *Assertions) needsBody() bool {
+
+    return len(a.Contains) > 0 || len(a.NotContains) > 0 || len(a.Matches) > 0 || len(a.NotMatches) > 0 ||
+           len(a.JSON) > 0
+}
+
+// Check returns a description of each assertion that the response fails.
+// The body is only checked if it is not nil. Assertions shared by several
+// Monitors must be validated before they are used.
+func (a *Assertions) Check(code int, header http.Header, body []byte) []string {
+
+    if !a.prepared {
+        if err := a.Validate(); err != nil {
+            return []string{err.Error()}
+        }
+    }
+
+    var problems []string
+    if len(a.Status) > 0 {
+        ok := false
+        for _, want := range a.Status {
+            ok = ok || code == want
+        }
+        if !ok {
+            problems = append(problems, fmt.Sprintf("status code %d, expected %s", code, joinInts(a.Status)))
+        }
+    }
+
+    for _, name := range sortedNames(a.Headers) {
+        want := a.Headers[name]
+        values, ok := header[http.CanonicalHeaderKey(name)]
+        switch {
+        case !ok:
+            problems = append(problems, fmt.Sprintf("header %s is missing", name))
+        case want != "" && !containsAny(values, want):
+            problems = append(problems, fmt.Sprintf("header %s is '%s', expected '%s'", name, strings.Join(values, ", "), want))
+        }
+    }
+    for _, name := range sortedNames(a.NoHeaders) {
+        unwanted := a.NoHeaders[name]
+        values, ok := header[http.CanonicalHeaderKey(name)]
+        switch {
+        case ok && unwanted == "":
+            problems = append(problems, fmt.Sprintf("header %s is present", name))
+        case ok && containsAny(values, unwanted):
+            problems = append(problems, fmt.Sprintf("header %s is '%s', which contains '%s'", name, strings.Join(values, ", "), unwanted))
+        }
+    }
+
+    if body == nil {
+        return problems
+    }
+    for _, s := range a.Contains {
+        if !bytes.Contains(body, []byte(s)) {
+            problems = append(problems, fmt.Sprintf("body does not contain '%s'", s))
+        }
+    }
+    for _, s := range a.NotContains {
+        if bytes.Contains(body, []byte(s)) {
+            problems = append(problems, fmt.Sprintf("body contains '%s'", s))
+        }
+    }
+    for _, re := range a.matches {
+        if !re.Match(body) {
+            problems = append(problems, fmt.Sprintf("body does not match '%s'", re))
+        }
+    }
+    for _, re := range a.notMatches {
+        if found := re.Find(body); found != nil {
+            problems = append(problems, fmt.Sprintf("body matches '%s' (found '%s')", re, truncate(string(found), 60)))
+        }
+    }
+    if len(a.json) > 0 {
+        problems = append(problems, checkJSON(a.json, body)...)
+    }
+    return problems
+}
+
+// checkAssertions raises an alert for each failed assertion.
+func (m *Monitor) checkAssertions(res *Result, body []byte) {
+
+    if res.Redirected {
+        body = nil                      // there is no body to check
+    } else if body == nil {
+        body = []byte{}                 // the body is empty
+    }
+    for _, problem := range m.Assert.Check(res.StatusCode, res.Header, body) {
+        res.alert(AlertAssertion, "assertion failed: %s", problem)
+    }
+}
+
+func containsAny(values []string, s string) bool {
+
+    for _, v := range values {
+        if strings.Contains(v, s) {
+            return true
+        }
+    }
+    return false
+}
+
+func sortedNames(m map[string]string) []string {
+
+    var names []string
+    for name := range m {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+func joinInts(ints []int) string {
+
+    s := make([]string, len(ints))
+    for i, n := range ints {
+        s[i] = fmt.Sprint(n)
+    }
+    return strings.Join(s, " or ")
+}
@@ -0,0 +1,65 @@
+package heartbeat
+
+import (
+    "net/http"
+    "reflect"
+    "sync"
+    "testing"
+)
+
+func TestAssertionsCheck(t *testing.T) {
+
+    header := http.Header{"Content-Type": {"application/json; charset=utf-8"}, "Server": {"nginx"}}
+    body := []byte(`{"status": "ok", "items": [1, 2]}`)
+    for _, tc := range []struct {
+        name     string
+        a        Assertions
+        code     int
+        body     []byte
+        problems []string
+    }{
+        {"none",              Assertions{},                                        500, body, nil},
+        {"status",            Assertions{Status: []int{200, 204}},                 200, body, nil},
+        {"wrong status",      Assertions{Status: []int{200, 204}},                 503, body, []string{"status code 503, expected 200 or 204"}},
+        {"header",            Assertions{Headers: map[string]string{"content-type": "application/json"}}, 200, body, nil},
+        {"missing header",    Assertions{Headers: map[string]string{"X-Request-Id": ""}}, 200, body, []string{"header X-Request-Id is missing"}},
+        {"wrong header",      Assertions{Headers: map[string]string{"Content-Type": "text/html"}}, 200, body,
+                              []string{"header Content-Type is 'application/json; charset=utf-8', expected 'text/html'"}},
+        {"forbidden header",  Assertions{NoHeaders: map[string]string{"Server": ""}},  200, body, []string{"header Server is present"}},
+        {"contains",          Assertions{Contains: []string{`"ok"`}, NotContains: []string{"error"}}, 200, body, nil},
+        {"does not contain",  Assertions{Contains: []string{"healthy"}},               200, body, []string{"body does not contain 'healthy'"}},
+        {"matches",           Assertions{Matches: []string{`"items": \[[0-9]`}},      200, body, nil},
+        {"json",              Assertions{JSON: []string{"$.items.length() == 2"}},    200, body, nil},
+        {"json fails",        Assertions{JSON: []string{"$.status == down"}},         200, body, []string{`$.status == down (actual "ok")`}},
+        {"body not checked",  Assertions{Contains: []string{"healthy"}},               200, nil,  nil},
+    } {
+        if err := tc.a.Validate(); err != nil {
+            t.Errorf("%s: %v", tc.name, err)
+            continue
+        }
+        if p := tc.a.Check(tc.code, header, tc.body); !reflect.DeepEqual(p, tc.problems) {
+            t.Errorf("%s: problems %q, want %q", tc.name, p, tc.problems)
+        }
+    }
+}
+
+// Validated Assertions may be shared by Monitors, as the defaults in a
+// configuration file are.
+func TestAssertionsShared(t *testing.T) {
+
+    a := &Assertions{Status: []int{200}}
+    if err := a.Validate(); err != nil {
+        t.Fatal(err)
+    }
+    var wg sync.WaitGroup
+    for i := 0; i < 4; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            if p := a.Check(200, nil, nil); p != nil {
+                t.Errorf("problems %q", p)
+            }
+        }()
+    }
+    wg.Wait()
+}
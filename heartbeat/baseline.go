@@ -0,0 +1,235 @@
+package heartbeat
+
+import (
+    "fmt"
+    "math"
+    "sort"
+    "strings"
+)
+
+// Strategy selects how the baseline for response times or sizes is
+// established, and so what counts as a significant variance.
+type Strategy string
+
+const (
+    // StrategyVariance compares each fetch with the previous baseline,
+    // allowing the Monitor's Variance percent either way. The first fetch
+    // sets the baseline, as does every fetch that varies too much.
+    StrategyVariance Strategy = ""
+
+    StrategyMean     Strategy = "mean"     // rolling mean, +/- Deviations standard deviations
+    StrategyEWMA     Strategy = "ewma"     // exponentially weighted moving average, +/- Deviations
+    StrategyMedian   Strategy = "median"   // rolling median, +/- Deviations (scaled) median absolute deviations
+    StrategyFixed    Strategy = "fixed"    // absolute Min and Max
+)
+
+const (
+    DefaultWindow     = 20      // samples
+    DefaultWarmUp     = 5       // samples
+    DefaultDeviations = 3
+    DefaultAlpha      = 0.3
+)
+
+// BaselineConfig describes the baseline for one measurement - response
+// time (in milliseconds, ignoring the first DNS lookup) or response size
+// (in bytes). Zero values mean the defaults.
+//
+// The statistical strategies (mean, ewma and median) do not alert until
+// WarmUp samples have been seen, and never alert on a difference of less
+// than the Monitor's Variance percent. Every sample is added to the
+// baseline, so a single outlier has only a passing effect on it.
+type BaselineConfig struct {
+    Strategy   Strategy `json:"strategy"`
+    Window     int      `json:"window"`       // samples kept for mean and median
+    WarmUp     int      `json:"warmup"`       // samples needed before alerting
+    Deviations float64  `json:"deviations"`   // allowable deviations from the centre
+    Alpha      float64  `json:"alpha"`        // ewma smoothing factor, between 0 and 1
+    Min        float64  `json:"min"`          // fixed: lower limit (0 = none)
+    Max        float64  `json:"max"`          // fixed: upper limit (0 = none)
+}
+
+// ParseStrategy accepts "variance", "mean", "ewma", "median" or "fixed",
+// ignoring case. An empty string is StrategyVariance.
+func ParseStrategy(s string) (Strategy, error) {
+
+    switch st := Strategy(strings.ToLower(strings.TrimSpace(s))); st {
+    case "", "variance":
+        return StrategyVariance, nil
+    case StrategyMean, StrategyEWMA, StrategyMedian, StrategyFixed:
+        return st, nil
+    }
+    return StrategyVariance, fmt.Errorf("unknown baseline strategy '%s' (use variance, mean, ewma, median or fixed)", s)
+}
+
+// Validate checks that the baseline settings are usable.
+func (c *BaselineConfig) Validate() error {
+
+    var err error
+    if c.Strategy, err = ParseStrategy(string(c.Strategy)); err != nil {
+        return err
+    }
+    switch {
+    case c.Window < 0 || c.WarmUp < 0 || c.Deviations < 0 || c.Min < 0 || c.Max < 0:
+        return fmt.Errorf("baseline settings must not be negative")
+    case c.Alpha < 0 || c.Alpha > 1:
+        return fmt.Errorf("baseline alpha must be between 0 and 1")
+    case c.Strategy == StrategyFixed && c.Min == 0 && c.Max == 0:
+        return fmt.Errorf("fixed baseline needs a min or a max")
+    case c.Max > 0 && c.Min > c.Max:
+        return fmt.Errorf("baseline min must not be greater than max")
+    case c.Strategy != StrategyFixed && (c.Min > 0 || c.Max > 0):
+        return fmt.Errorf("baseline min and max are only used by the fixed strategy")
+    }
+    return nil
+}
+
+func (c BaselineConfig) window() int {
+
+    if c.Window > 0 {
+        return c.Window
+    }
+    return DefaultWindow
+}
+
+func (c BaselineConfig) warmUp() int {
+
+    n := c.WarmUp
+    if n == 0 {
+        n = DefaultWarmUp
+    }
+    if c.Strategy != StrategyEWMA && n > c.window() {
+        n = c.window()
+    }
+    return n
+}
+
+func (c BaselineConfig) deviations() float64 {
+
+    if c.Deviations > 0 {
+        return c.Deviations
+    }
+    return DefaultDeviations
+}
+
+func (c BaselineConfig) alpha() float64 {
+
+    if c.Alpha > 0 {
+        return c.Alpha
+    }
+    return DefaultAlpha
+}
+
+// series holds the recent samples of a measurement.
+type series struct {
+    samples []float64   // the last Window samples, oldest first
+    count   int         // samples seen in all
+    mean    float64     // ewma
+    vari    float64     // ewma variance
+}
+
+// limits returns the centre of the baseline and the range of acceptable
+// values, and whether there are enough samples to judge by. The floor is
+// the smallest allowable difference from the centre.
+func (s *series) limits(c BaselineConfig, floor float64) (centre, lo, hi float64, ready bool) {
+
+    if c.Strategy == StrategyFixed {
+        if c.Max == 0 {
+            return c.Min, c.Min, math.Inf(1), true
+        }
+        return (c.Min + c.Max) / 2, c.Min, c.Max, true
+    }
+    if s.count == 0 {
+        return 0, 0, 0, false
+    }
+
+    var spread float64
+    switch c.Strategy {
+    case StrategyMean:
+        var sum, sq float64
+        for _, x := range s.samples {
+            sum += x
+        }
+        centre = sum / float64(len(s.samples))
+        for _, x := range s.samples {
+            sq += (x - centre) * (x - centre)
+        }
+        spread = math.Sqrt(sq / float64(len(s.samples)))
+    case StrategyEWMA:
+        centre = s.mean
+        spread = math.Sqrt(s.vari)
+    case StrategyMedian:
+        centre = median(s.samples)
+        dev := make([]float64, len(s.samples))
+        for i, x := range s.samples {
+            dev[i] = math.Abs(x - centre)
+        }
+        spread = 1.4826 * median(dev)   // comparable to a standard deviation
+    }
+
+    spread *= c.deviations()
+    if min := centre * floor / 100; spread < min {
+        spread = min
+    }
+    return centre, math.Max(0, centre - spread), centre + spread, s.count >= c.warmUp()
+}
+
+// add adds a sample to the series.
+func (s *series) add(c BaselineConfig, x float64) {
+
+    if s.count == 0 {
+        s.mean = x
+    } else {
+        a := c.alpha()
+        d := x - s.mean
+        s.mean += a * d
+        s.vari  = (1 - a) * (s.vari + a * d * d)
+    }
+    s.count++
+
+    s.samples = append(s.samples, x)
+    if w := c.window(); len(s.samples) > w {
+        s.samples = append(s.samples[:0], s.samples[len(s.samples) - w:]...)
+    }
+}
+
+func median(x []float64) float64 {
+
+    sorted := append([]float64(nil), x...)
+    sort.Float64s(sorted)
+    n := len(sorted)
+    if n % 2 == 1 {
+        return sorted[n / 2]
+    }
+    return (sorted[n / 2 - 1] + sorted[n / 2]) / 2
+}
+
+// checkSeries compares a measurement with its baseline, then adds it to
+// the baseline. It returns the new centre and limits of the baseline.
+func (m *Monitor) checkSeries(res *Result, kind AlertKind, s *series, c BaselineConfig, x float64, unit string) (centre, lo, hi float64) {
+
+    centre, lo, hi, ready := s.limits(c, float64(m.Variance))
+    m.debugf("%s baseline (%s) is %.0f %s, range %.0f - %.0f %s (%d samples)\n",
+             kind, strategyName(c.Strategy), centre, unit, lo, hi, unit, s.count)
+    switch {
+    case !ready:
+    case x > hi:
+        res.alert(kind, "%s %.0f %s, above the %s baseline (at most %.0f %s)",
+                  kind, x, unit, strategyName(c.Strategy), hi, unit)
+    case x < lo:
+        res.alert(kind, "%s %.0f %s, below the %s baseline (at least %.0f %s)",
+                  kind, x, unit, strategyName(c.Strategy), lo, unit)
+    }
+    if c.Strategy != StrategyFixed {
+        s.add(c, x)
+    }
+    centre, lo, hi, _ = s.limits(c, float64(m.Variance))
+    return centre, lo, hi
+}
+
+func strategyName(s Strategy) string {
+
+    if s == StrategyVariance {
+        return "variance"
+    }
+    return string(s)
+}
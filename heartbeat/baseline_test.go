@@ -0,0 +1,137 @@
+package heartbeat
+
+import (
+    "math"
+    "reflect"
+    "testing"
+)
+
+func TestSeriesLimits(t *testing.T) {
+
+    inf := math.Inf(1)
+    for _, tc := range []struct {
+        name    string
+        config  BaselineConfig
+        floor   float64         // percent
+        samples []float64
+        centre  float64
+        lo, hi  float64
+        ready   bool
+    }{
+        {"no samples",        BaselineConfig{Strategy: StrategyMean},                     0, nil,                        0, 0, 0, false},
+        {"warming up",        BaselineConfig{Strategy: StrategyMean},                     0, []float64{5, 5, 5, 5},      5, 5, 5, false},
+        {"mean",              BaselineConfig{Strategy: StrategyMean, Deviations: 1},      0, []float64{2, 4, 4, 4, 5, 5, 7, 9}, 5, 3, 7, true},
+        {"mean, default deviations", BaselineConfig{Strategy: StrategyMean},              0, []float64{2, 4, 4, 4, 5, 5, 7, 9}, 5, 0, 11, true},
+        {"mean within floor", BaselineConfig{Strategy: StrategyMean},                     10, []float64{100, 100, 100, 100, 100}, 100, 90, 110, true},
+        {"mean of window",    BaselineConfig{Strategy: StrategyMean, Window: 2, Deviations: 1}, 0, []float64{50, 1, 3}, 2, 1, 3, true},
+        {"ewma",              BaselineConfig{Strategy: StrategyEWMA, Alpha: 0.5, Deviations: 1, WarmUp: 2}, 0, []float64{10, 20}, 15, 10, 20, true},
+        {"median",            BaselineConfig{Strategy: StrategyMedian, Deviations: 1},    0, []float64{1, 2, 3, 4, 100}, 3, 3 - 1.4826, 3 + 1.4826, true},
+        {"fixed",             BaselineConfig{Strategy: StrategyFixed, Min: 10, Max: 30},  0, nil,                        20, 10, 30, true},
+        {"fixed, no maximum", BaselineConfig{Strategy: StrategyFixed, Min: 10},           0, nil,                        10, 10, inf, true},
+    } {
+        var s series
+        for _, x := range tc.samples {
+            s.add(tc.config, x)
+        }
+        centre, lo, hi, ready := s.limits(tc.config, tc.floor)
+        if !near(centre, tc.centre) || !near(lo, tc.lo) || !near(hi, tc.hi) || ready != tc.ready {
+            t.Errorf("%s: got %v, %v - %v (ready %v), want %v, %v - %v (ready %v)",
+                     tc.name, centre, lo, hi, ready, tc.centre, tc.lo, tc.hi, tc.ready)
+        }
+    }
+}
+
+func near(x, y float64) bool {
+
+    return x == y || math.Abs(x - y) < 1e-9
+}
+
+func TestSeriesAdd(t *testing.T) {
+
+    c := BaselineConfig{Window: 3, Alpha: 0.5}
+    var s series
+    for _, x := range []float64{1, 2, 3, 4, 5} {
+        s.add(c, x)
+    }
+    if !reflect.DeepEqual(s.samples, []float64{3, 4, 5}) || s.count != 5 {
+        t.Errorf("samples %v (count %d), want the last 3 of 5", s.samples, s.count)
+    }
+    // 1, then 1.5, 2.25, 3.125 and 4.0625 with an alpha of 0.5
+    if s.mean != 4.0625 {
+        t.Errorf("ewma = %v, want 4.0625", s.mean)
+    }
+}
+
+func TestBaselineWarmUp(t *testing.T) {
+
+    for _, tc := range []struct {
+        config BaselineConfig
+        want   int
+    }{
+        {BaselineConfig{},                                      DefaultWarmUp},
+        {BaselineConfig{WarmUp: 8},                             8},
+        {BaselineConfig{Window: 3, WarmUp: 8},                  3},
+        {BaselineConfig{Strategy: StrategyEWMA, Window: 3, WarmUp: 8}, 8},
+    } {
+        if got := tc.config.warmUp(); got != tc.want {
+            t.Errorf("%+v: warm up %d, want %d", tc.config, got, tc.want)
+        }
+    }
+}
+
+func TestMedian(t *testing.T) {
+
+    for _, tc := range []struct {
+        x    []float64
+        want float64
+    }{
+        {[]float64{7},              7},
+        {[]float64{3, 1, 2},        2},
+        {[]float64{4, 1, 3, 2},     2.5},
+    } {
+        if got := median(tc.x); got != tc.want {
+            t.Errorf("median(%v) = %v, want %v", tc.x, got, tc.want)
+        }
+    }
+}
+
+func TestLimit(t *testing.T) {
+
+    for _, tc := range []struct {
+        hi   float64
+        want uint64
+    }{
+        {42.9,          42},
+        {math.Inf(1),   math.MaxInt64},
+        {1e30,          math.MaxInt64},
+    } {
+        if got := limit(tc.hi); got != tc.want {
+            t.Errorf("limit(%v) = %v, want %v", tc.hi, got, tc.want)
+        }
+    }
+}
+
+// A fixed baseline with no maximum has no upper limit, which is kept as
+// the largest possible value.
+func TestCheckSizeFixed(t *testing.T) {
+
+    m := NewMonitor("http://localhost/")
+    m.SizeBaseline = BaselineConfig{Strategy: StrategyFixed, Min: 10}
+    for _, tc := range []struct {
+        bytes int64
+        alert bool
+    }{
+        {5,         true},
+        {10,        false},
+        {1 << 40,   false},
+    } {
+        res := &Result{Bytes: tc.bytes}
+        m.checkSize(res)
+        if (len(res.Alerts) > 0) != tc.alert {
+            t.Errorf("%d bytes: alerts %+v", tc.bytes, res.Alerts)
+        }
+        if m.baseline.BytesLo != 10 || m.baseline.BytesHi != math.MaxInt64 {
+            t.Errorf("%d bytes: baseline %d - %d", tc.bytes, m.baseline.BytesLo, m.baseline.BytesHi)
+        }
+    }
+}
@@ -0,0 +1,380 @@
+package heartbeat
+
+import (
+    "context"
+    "crypto/x509"
+    "errors"
+    "fmt"
+    "math"
+    "net/http"
+    "time"
+)
+
+// AlertKind identifies the type of an Alert.
+type AlertKind string
+
+const (
+    AlertTimeout     AlertKind = "timeout"        // fetch did not complete in time
+    AlertUnreachable AlertKind = "unreachable"    // host could not be connected to
+    AlertError       AlertKind = "error"          // request failed for some other reason
+    AlertRecovered   AlertKind = "recovered"      // target is OK again (a notice)
+    AlertFlapping    AlertKind = "flapping"       // target started or stopped flapping (a notice)
+    AlertTime        AlertKind = "time"           // round trip time variance
+    AlertSize        AlertKind = "size"           // response size variance
+    AlertValue       AlertKind = "value"          // extracted value threshold or change
+    AlertProtocol    AlertKind = "protocol"       // server answered with an unexpected HTTP version
+    AlertPhase       AlertKind = "phase"          // a phase of the fetch took longer than its limit
+    AlertAssertion   AlertKind = "assertion"      // response failed one of the Monitor's Assertions
+    AlertRedirect    AlertKind = "redirect"       // chain of redirects was not as expected (or changed)
+    AlertTLS         AlertKind = "tls"            // certificate expiring or not matching, or TLS downgraded
+)
+
+// Alert describes a significant deviation noticed during a fetch.
+type Alert struct {
+    Kind     AlertKind
+    Category ErrorCategory     // why the fetch failed, if it did
+    Time     time.Time
+    Message  string
+}
+
+// Result is the outcome of a single fetch.
+type Result struct {
+    Name       string
+    URL        string
+    Start      time.Time
+    Trip       time.Duration   // round trip time
+    FirstDNS   time.Duration   // first DNS lookup (ignored for variance purposes)
+    DNS        time.Duration   // total of all DNS lookups
+    Connect    time.Duration   // total of all connections
+    TLS        time.Duration   // total of all TLS handshakes
+    Write      time.Duration   // total time writing requests
+    Wait       time.Duration   // server processing: from writing the final request to its first byte
+    TTFB       time.Duration   // time to the first byte of the final response
+    Transfer   time.Duration   // from the first byte of the final response to the end of its body
+    StatusCode int
+    Status     string
+    ProtoMajor int
+    ProtoMinor int
+    Redirected bool            // the final response is a redirect (which was not followed)
+    Hops       []Hop           // every request made, following redirects
+    Steps      []*Result       // of each step of a Scenario run, in turn
+    Header     http.Header     // of the final response
+    TLSInfo    *TLSInfo        // of the final response, if it was over HTTPS
+    Bytes      int64
+    Value      *float64        // extracted by the Monitor's ValueCheck, if any
+    Alerts     []Alert
+    Notices    []Alert         // informational, such as recovery from an alert
+
+    // State is the state of the target after this fetch, and Suppressed
+    // the alerts and notices held back, according to the AlertPolicy.
+    // These are only set by Run.
+    State      Status
+    Flapping   bool
+    Failing    int             // consecutive failed checks, including this one
+    Suppressed []Alert
+
+    // Err is any error returned by the fetch or while reading the
+    // response body, and Category its classification.
+    Err        error
+    Category   ErrorCategory
+}
+
+// Phase is the duration of one phase of a fetch.
+type Phase struct {
+    Name     string
+    Duration time.Duration
+}
+
+// PhaseNames lists the phases returned by Result.Phases, in order.
+var PhaseNames = []string{"dns", "connect", "tls", "write", "wait", "transfer", "ttfb", "total"}
+
+func knownPhase(name string) bool {
+
+    for _, n := range PhaseNames {
+        if n == name {
+            return true
+        }
+    }
+    return false
+}
+
+// Phases returns the timings of each phase of the fetch: DNS lookup,
+// TCP connect, TLS handshake, request write, server processing (wait)
+// and body transfer - followed by the time to first byte and the total
+// round trip time, which include the phases before them.
+func (r *Result) Phases() []Phase {
+
+    return []Phase{
+        {"dns",      r.DNS},
+        {"connect",  r.Connect},
+        {"tls",      r.TLS},
+        {"write",    r.Write},
+        {"wait",     r.Wait},
+        {"transfer", r.Transfer},
+        {"ttfb",     r.TTFB},
+        {"total",    r.Trip},
+    }
+}
+
+// Phase returns the duration of the named phase, and whether there is
+// such a phase.
+func (r *Result) Phase(name string) (time.Duration, bool) {
+
+    for _, p := range r.Phases() {
+        if p.Name == name {
+            return p.Duration, true
+        }
+    }
+    return 0, false
+}
+
+// OK reports whether the fetch completed without errors or alerts.
+func (r *Result) OK() bool {
+
+    return r.Err == nil && len(r.Alerts) == 0
+}
+
+func (r *Result) alert(kind AlertKind, format string, args ...interface{}) {
+
+    r.Alerts = append(r.Alerts, Alert{Kind: kind, Time: time.Now(), Message: fmt.Sprintf(format, args...)})
+}
+
+// failed raises an alert for a failed fetch, noting why it failed.
+func (r *Result) failed(kind AlertKind, format string, args ...interface{}) {
+
+    r.Alerts = append(r.Alerts, Alert{Kind: kind, Category: r.Category, Time: time.Now(), Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *Result) notice(kind AlertKind, format string, args ...interface{}) {
+
+    r.Notices = append(r.Notices, Alert{Kind: kind, Time: time.Now(), Message: fmt.Sprintf(format, args...)})
+}
+
+// ConnectError reports that the target host could not be connected to.
+type ConnectError struct {
+    Net  string
+    Addr string
+    Err  error
+}
+
+func (e *ConnectError) Error() string {
+
+    return fmt.Sprintf("unable to connect to host '%v', net '%v': %v", e.Addr, e.Net, e.Err)
+}
+
+func (e *ConnectError) Unwrap() error {
+
+    return e.Err
+}
+
+// CheckOnce fetches the target, redirecting as necessary, and compares
+// the response with the baseline. Variances will generate alerts as will
+// a response greater than the specified timeout period.
+//
+// A host that cannot be connected to is reported as unreachable.
+//
+// Every alert is reported, however many fetches in a row raise it:
+// it is Run that applies the Monitor's AlertPolicy.
+//
+// The returned error is only non-nil if the check itself could not be
+// carried out; failed fetches are reported in the Result.
+func (m *Monitor) CheckOnce(ctx context.Context) (*Result, error) {
+
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if m.Scenario != nil {
+        return m.checkScenario(ctx)
+    }
+
+    res, body, err := m.fetch(ctx, nil)
+    if err != nil {
+        return nil, err
+    }
+    if res.Err != nil {
+        m.fetchFailed(res)
+        if res.TLSInfo != nil {
+            m.checkTLS(res, &m.tlsVersion)      // the certificates that failed verification
+        }
+        return res, nil
+    }
+
+    if m.Protocol != ProtocolAny {
+        if major, minor := m.Protocol.Version(); res.ProtoMajor != major || res.ProtoMinor != minor {
+            want := fmt.Sprintf("HTTP/%d.%d", major, minor)
+            if want != string(m.Protocol) {
+                want += " (" + string(m.Protocol) + ")"
+            }
+            res.alert(AlertProtocol, "expected %s, server answered HTTP/%d.%d",
+                      want, res.ProtoMajor, res.ProtoMinor)
+        }
+    }
+
+    m.checkRedirects(res)
+    if res.TLSInfo != nil {
+        m.checkTLS(res, &m.tlsVersion)
+    }
+    if m.Assert != nil {
+        m.checkAssertions(res, body)
+    }
+    if !res.Redirected {        // if this is a redirect, don't care about body
+        if m.Value != nil {
+            m.checkValue(res, body)
+        }
+        m.checkSize(res)
+    }
+    m.checkTime(res)
+    m.checkPhases(res)
+
+    return res, nil
+}
+
+// checkPhases raises an alert for each phase of the fetch that took
+// longer than its limit - the critical limit, if it is over both.
+func (m *Monitor) checkPhases(res *Result) {
+
+    for _, p := range res.Phases() {
+        if limit, ok := m.PhaseCritical[p.Name]; ok && p.Duration > limit {
+            res.alert(AlertPhase, "%s took %v, critical above %v", p.Name, p.Duration.Round(time.Microsecond), limit)
+        } else if limit, ok := m.PhaseWarning[p.Name]; ok && p.Duration > limit {
+            res.alert(AlertPhase, "%s took %v, warning above %v", p.Name, p.Duration.Round(time.Microsecond), limit)
+        }
+    }
+}
+
+// thresholds returns the Thresholds that decide the status of the
+// Monitor's checks: just its phase limits, as the other checks raise
+// alerts of their own.
+func (m *Monitor) thresholds() Thresholds {
+
+    return Thresholds{PhaseWarning: m.PhaseWarning, PhaseCritical: m.PhaseCritical}
+}
+
+// fetchFailed raises the alert for a failed fetch: a host that cannot
+// be connected to is reported as unreachable.
+func (m *Monitor) fetchFailed(res *Result) {
+
+    if _, ok := res.Err.(*ConnectError); ok || res.Category.isUnreachable() {
+        res.failed(AlertUnreachable, "host unreachable (%s): %v", res.Category, res.Err)
+        return
+    }
+    if res.StatusCode == 0 {
+        var hostname x509.HostnameError
+        var pin *PinError
+        if res.Category == ErrorTimeout {
+            res.failed(AlertTimeout, "Timeout on request (use verbose option for more details)")
+        } else if errors.As(res.Err, &hostname) {
+            res.failed(AlertTLS, "hostname mismatch: %v", hostname)
+        } else if errors.As(res.Err, &pin) {
+            res.failed(AlertTLS, "%v", pin)
+        } else {
+            res.failed(AlertError, "request failed (%s): %v", res.Category, res.Err)
+        }
+        m.debugf("Error on request:\n%v\n", res.Err)
+    }
+}
+
+// checkSize compares the length of the response body against the baseline.
+func (m *Monitor) checkSize(res *Result) {
+
+    if m.SizeBaseline.Strategy != StrategyVariance {
+        centre, lo, hi := m.checkSeries(res, AlertSize, &m.sizes, m.SizeBaseline, float64(res.Bytes), "bytes")
+        b := &m.baseline
+        b.Bytes   = uint64(centre)
+        b.BytesLo = uint64(lo)
+        b.BytesHi = limit(hi)
+        return
+    }
+
+    v  := m.Variance
+    bc := uint64(res.Bytes)
+    lo := float64(bc) * (1.0 - (float64(v) / 100.0))
+    hi := float64(bc) * (1.0 + (float64(v) / 100.0))
+    m.debugf("response body had %v bytes, a %v%% variance is ~ %v - %v\n", bc, v, lo, hi)
+
+    b := &m.baseline
+    if b.Bytes == 0 {
+        b.Bytes   = bc
+        b.BytesLo = uint64(lo)
+        b.BytesHi = uint64(hi)
+    } else {
+        if bc < b.BytesLo || bc > b.BytesHi {
+            res.alert(AlertSize, "previously %v bytes, now %v bytes", b.Bytes, bc)
+            b.Bytes   = bc
+            b.BytesLo = uint64(lo)
+            b.BytesHi = uint64(hi)
+        }
+    }
+}
+
+// checkTime compares the round trip time (ignoring the first DNS lookup)
+// against the baseline.
+func (m *Monitor) checkTime(res *Result) {
+
+    m.debugf("%s %s%s%d.%d %s\n", time.Now(), " - HTTP", "/", res.ProtoMajor, res.ProtoMinor, res.Status)
+
+    b := &m.baseline
+    if m.TimeBaseline.Strategy != StrategyVariance {
+        centre, lo, hi := m.checkSeries(res, AlertTime, &m.times, m.TimeBaseline,
+                                        float64(res.Trip - res.FirstDNS) / float64(time.Millisecond), "ms")
+        b.Trip   = int64(centre)
+        b.Time   = int64(centre)
+        b.TimeLo = int64(lo)
+        b.TimeHi = int64(limit(hi))
+        return
+    }
+
+    elapsed := res.Trip
+    varTime := elapsed - res.FirstDNS
+    elapsed /= time.Millisecond  // reframe in milliseconds
+    varTime /= time.Millisecond  // reframe in milliseconds
+
+    v := m.Variance
+    tripTime := int64(elapsed)
+    respTime := int64(varTime)
+    respLo   := float64(varTime) * (1.0 - (float64(v) / 100.0))
+    respHi   := float64(varTime) * (1.0 + (float64(v) / 100.0))
+    m.debugf("round trip took %v ms; %v ms ignoring first DNS, a %v%% variance is ~ %v - %v ms\n", tripTime, respTime, v, respLo, respHi)
+
+    if b.Trip == 0 {
+        b.Trip   = tripTime
+        b.Time   = respTime
+        b.TimeHi = int64(respHi)
+        b.TimeLo = int64(respLo)
+    } else {
+        if respTime < b.TimeLo || respTime > b.TimeHi {
+            res.alert(AlertTime, "previously %v ms, now %v ms", b.Trip, tripTime)
+            b.Trip   = tripTime
+            b.Time   = respTime
+            b.TimeLo = int64(respLo)
+            b.TimeHi = int64(respHi)
+        }
+    }
+}
+
+// limit converts the upper limit of a baseline, which may be infinite.
+func limit(hi float64) uint64 {
+
+    if hi > math.MaxInt64 {
+        return math.MaxInt64
+    }
+    return uint64(hi)
+}
+
+// checkValue extracts the value from the body and compares it with the
+// thresholds and the value found by the previous fetch.
+func (m *Monitor) checkValue(res *Result, body []byte) {
+
+    val, err := m.Value.Extract(body)
+    if err != nil {
+        res.alert(AlertValue, "unable to extract value: %v", err)
+        return
+    }
+    res.Value = &val
+    m.debugf("extracted value %v\n", val)
+
+    for _, problem := range m.Value.check(val, m.value) {
+        res.alert(AlertValue, "%s", problem)
+    }
+    m.value = &val
+}
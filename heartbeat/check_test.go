@@ -0,0 +1,88 @@
+package heartbeat
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+// A phase over its limit raises an alert from CheckOnce, and makes the
+// state of the target WARNING or CRITICAL.
+func TestCheckPhases(t *testing.T) {
+
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+        time.Sleep(20 * time.Millisecond)
+        w.Write([]byte("ok"))
+    }))
+    defer srv.Close()
+
+    for _, tc := range []struct {
+        name     string
+        warning  map[string]time.Duration
+        critical map[string]time.Duration
+        alerts   []string
+        state    Status
+    }{
+        {"within limits", map[string]time.Duration{"wait": time.Minute}, nil, nil, StatusOK},
+        {"warning",       map[string]time.Duration{"wait": time.Millisecond}, nil,
+                          []string{"wait took"}, StatusWarning},
+        {"critical",      map[string]time.Duration{"wait": time.Millisecond, "dns": time.Hour},
+                          map[string]time.Duration{"total": time.Millisecond},
+                          []string{"wait took", "total took"}, StatusCritical},
+        {"critical over both", map[string]time.Duration{"ttfb": time.Millisecond},
+                          map[string]time.Duration{"ttfb": 2 * time.Millisecond},
+                          []string{"ttfb took"}, StatusCritical},
+    } {
+        m := NewMonitor(srv.URL)
+        m.PhaseWarning, m.PhaseCritical = tc.warning, tc.critical
+        if err := m.Validate(); err != nil {
+            t.Fatalf("%s: %v", tc.name, err)
+        }
+        res, err := m.CheckOnce(context.Background())
+        if err != nil || res.Err != nil {
+            t.Fatalf("%s: %v %v", tc.name, err, res.Err)
+        }
+        var alerts []string
+        for _, a := range res.Alerts {
+            if a.Kind == AlertPhase {
+                alerts = append(alerts, a.Message)
+            }
+        }
+        if len(alerts) != len(tc.alerts) {
+            t.Errorf("%s: alerts %q, want %q", tc.name, alerts, tc.alerts)
+        } else {
+            for i, want := range tc.alerts {
+                if !strings.Contains(alerts[i], want) {
+                    t.Errorf("%s: alert %q, want %q", tc.name, alerts[i], want)
+                }
+            }
+        }
+        m.track(res)
+        if m.State() != tc.state {
+            t.Errorf("%s: state %s, want %s", tc.name, m.State(), tc.state)
+        }
+    }
+}
+
+func TestValidatePhases(t *testing.T) {
+
+    for _, tc := range []struct {
+        limits map[string]time.Duration
+        err    string
+    }{
+        {map[string]time.Duration{"tls": time.Second, "total": time.Minute}, ""},
+        {map[string]time.Duration{"handshake": time.Second},                 "unknown phase 'handshake'"},
+        {map[string]time.Duration{"wait": 0},                                "wait limit must be greater than zero"},
+    } {
+        m := NewMonitor("http://localhost/")
+        m.PhaseCritical = tc.limits
+        err := m.Validate()
+        if tc.err == "" && err != nil || tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+            t.Errorf("%v: error = %v, want %q", tc.limits, err, tc.err)
+        }
+    }
+}
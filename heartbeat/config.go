@@ -0,0 +1,775 @@
+package heartbeat
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "net"
+    "net/http"
+    "net/url"
+    "reflect"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Config describes a number of targets to be monitored, in JSON:
+//
+//    {
+//        "concurrency": 10,
+//        "defaults": {
+//            "interval": "5m",
+//            "timeout":  "10s",
+//            "variance": 5,
+//            "headers":  { "User-Agent": "heartbeat" },
+//            "alerting": { "failures": 3, "successes": 2,
+//                          "flap_window": 10, "flap_changes": 4 },
+//            "time_baseline": { "strategy": "median", "window": 30 },
+//            "tls": { "expiry_days": 30, "min_version": "1.2" }
+//        },
+//        "targets": [
+//            { "name": "home", "url": "http://localhost",
+//              "redirects": { "max": 3, "expect": [ "https://localhost/" ] } },
+//            { "name": "api",  "url": "https://localhost/api", "interval": "30s",
+//              "size_baseline": { "strategy": "fixed", "min": 100, "max": 5000 },
+//              "phase_warning": { "tls": "200ms" }, "phase_critical": { "wait": "2s" } },
+//            { "name": "quote", "url": "https://localhost/quote.json",
+//              "value": { "jsonpath": "$.price", "below": 95.5, "change": 5 },
+//              "notify": [ "ops", "pager" ] },
+//            { "name": "shop", "url": "https://localhost/shop",
+//              "assert": { "status": [ 200 ], "contains": [ "Add to basket" ],
+//                          "headers": { "Content-Type": "text/html" },
+//                          "not_matches": [ "(?i)internal server error" ] } },
+//            { "name": "health", "url": "https://localhost/health",
+//              "assert": { "json": [ "$.status == 'ok'", "$.db.latency_ms < 100" ] } },
+//            { "name": "billing", "url": "https://billing.internal/ping",
+//              "tls": { "ca_files": [ "internal-ca.pem" ], "cert": "client.pem", "key": "client.key",
+//                       "pins": [ "sha256/47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=" ] } },
+//            { "name": "search", "url": "https://localhost/api/search", "method": "POST",
+//              "headers": { "Content-Type": "application/json" }, "body_file": "search.json",
+//              "query": { "limit": "10" }, "auth": { "bearer_token": "secret" } },
+//            { "name": "checkout", "interval": "15m", "scenario": {
+//                "vars":  { "base": "https://localhost" },
+//                "steps": [
+//                  { "name": "login", "url": "${base}/login", "method": "POST",
+//                    "body": "user=monitor&password=secret",
+//                    "headers": { "Content-Type": "application/x-www-form-urlencoded" },
+//                    "capture": { "token": "json:$.token" } },
+//                  { "name": "basket", "url": "${base}/api/basket",
+//                    "auth": { "bearer_token": "${token}" },
+//                    "assert": { "status": [ 200 ], "json": [ "$.items type array" ] } },
+//                  { "name": "logout", "url": "${base}/logout", "method": "POST" } ] } }
+//        ],
+//        "notifiers": {
+//            "ops":   { "type": "email", "server": "localhost:25",
+//                       "from": "heartbeat@localhost", "to": [ "ops@localhost" ] },
+//            "pager": { "type": "webhook", "url": "https://localhost/hooks/pager" },
+//            "log":   { "type": "command", "command": [ "logger", "-t", "heartbeat" ] }
+//        },
+//        "store": { "dir": "heartbeat-history", "retention": "720h" },
+//        "listen": ":9100"
+//    }
+//
+// Settings in "defaults" apply to every target unless the target
+// overrides them. Durations use Go syntax ("30s", "2m", "1h30m").
+// Alerts for a target are sent to each of the notifiers it lists.
+// phase_warning and phase_critical limit the phases of each fetch, by
+// the names in PhaseNames.
+// A target with a scenario (see Scenario) may leave out its url, which
+// is then that of the first step; it may not have a value, protocol or
+// expected redirects, as the steps are not checked for them.
+type Config struct {
+    Concurrency int                       `json:"concurrency"`   // maximum simultaneous fetches (0 = default)
+    Defaults    TargetConfig              `json:"defaults"`
+    Targets     []TargetConfig            `json:"targets"`
+    Notifiers   map[string]NotifierConfig `json:"notifiers"`
+    Store       *StoreConfig              `json:"store"`         // where results are kept, if anywhere
+    Listen      string                    `json:"listen"`        // address to serve /metrics and the dashboard on, if any
+
+    file        string
+    offsets     map[string]int64    // key path -> offset within file
+    data        []byte
+    monitors    []*Monitor          // built by ParseConfig
+}
+
+// TargetConfig holds the settings for a single target. Unset fields
+// are taken from the defaults.
+type TargetConfig struct {
+    Name          string            `json:"name"`
+    URL           string            `json:"url"`
+    Interval      string            `json:"interval"`
+    Timeout       string            `json:"timeout"`
+    Variance      *int              `json:"variance"`
+    Headers       map[string]string `json:"headers"`
+    Method        string            `json:"method"`          // default GET
+    Query         map[string]string `json:"query"`           // added to the URL's query string
+    Body          string            `json:"body"`
+    BodyFile      string            `json:"body_file"`       // read at startup, instead of body
+    Auth          *Auth             `json:"auth"`
+    UserAgent     string            `json:"user_agent"`
+    Protocol      string            `json:"protocol"`        // HTTP/1.0, HTTP/1.1, h2 or h2c
+    Notify        []string          `json:"notify"`          // names of notifiers
+    Alerting      *AlertPolicy      `json:"alerting"`
+    Redirects     *RedirectPolicy   `json:"redirects"`
+    TLS           *TLSPolicy        `json:"tls"`
+    PhaseWarning  map[string]string `json:"phase_warning"`   // phase name -> duration
+    PhaseCritical map[string]string `json:"phase_critical"`
+    TimeBaseline  *BaselineConfig   `json:"time_baseline"`   // in milliseconds
+    SizeBaseline  *BaselineConfig   `json:"size_baseline"`   // in bytes
+    Value         *ValueCheck       `json:"value"`
+    Assert        *Assertions       `json:"assert"`
+    Scenario      *Scenario         `json:"scenario"`        // run instead of fetching the url
+}
+
+// StoreConfig describes where the result of every check is kept (see Store).
+type StoreConfig struct {
+    Dir       string `json:"dir"`
+    Retention string `json:"retention"`   // as in "720h" (default forever)
+}
+
+// NotifierConfig describes where alerts are sent. The fields used
+// depend upon the type: "webhook", "email" or "command".
+type NotifierConfig struct {
+    Type     string            `json:"type"`
+    URL      string            `json:"url"`         // webhook
+    Headers  map[string]string `json:"headers"`     // webhook
+    Server   string            `json:"server"`      // email, as host:port
+    From     string            `json:"from"`        // email
+    To       []string          `json:"to"`          // email
+    Username string            `json:"username"`    // email
+    Password string            `json:"password"`    // email
+    Command  []string          `json:"command"`     // command, followed by its arguments
+}
+
+// ConfigError reports a problem with a configuration file, identifying
+// the offending key and the line on which it appears.
+type ConfigError struct {
+    File string
+    Line int        // zero if unknown
+    Key  string     // for example "targets[2].timeout"
+    Msg  string
+}
+
+func (e *ConfigError) Error() string {
+
+    var b bytes.Buffer
+    b.WriteString(e.File)
+    if e.Line > 0 {
+        b.WriteString(":" + strconv.Itoa(e.Line))
+    }
+    if e.Key != "" {
+        b.WriteString(": " + e.Key)
+    }
+    b.WriteString(": " + e.Msg)
+    return b.String()
+}
+
+// LoadConfig reads and validates a configuration file.
+func LoadConfig(file string) (*Config, error) {
+
+    data, err := ioutil.ReadFile(file)
+    if err != nil {
+        return nil, err
+    }
+    return ParseConfig(file, data)
+}
+
+// ParseConfig parses and validates a configuration; the file name
+// is only used in error messages.
+func ParseConfig(file string, data []byte) (*Config, error) {
+
+    c := &Config{file: file, data: data, offsets: map[string]int64{}}
+
+    if err := c.index(json.NewDecoder(bytes.NewReader(data)), "", reflect.TypeOf(c).Elem()); err != nil {
+        return nil, err
+    }
+    if err := json.Unmarshal(data, c); err != nil {
+        switch e := err.(type) {
+        case *json.UnmarshalTypeError:
+            return nil, &ConfigError{File: file, Line: c.lineAt(e.Offset), Key: fieldKey(e.Field),
+                                     Msg: fmt.Sprintf("expected %v, found %s", e.Type, e.Value)}
+        }
+        return nil, &ConfigError{File: file, Msg: err.Error()}
+    }
+    monitors, err := c.build()
+    if err != nil {
+        return nil, err
+    }
+    c.monitors = monitors
+    return c, nil
+}
+
+// Monitors returns the Monitor for each target, with the defaults applied.
+// They are built (and any files they refer to read) only once, when the
+// configuration is parsed, so each call returns the same Monitors.
+func (c *Config) Monitors() []*Monitor {
+
+    return c.monitors
+}
+
+// build returns a new Monitor for each target, with the defaults applied.
+func (c *Config) build() ([]*Monitor, error) {
+
+    if c.Concurrency < 0 {
+        return nil, c.errorf("concurrency", "must not be negative")
+    }
+    if c.Defaults.Name != "" {
+        return nil, c.errorf("defaults.name", "not allowed in defaults")
+    }
+    if c.Defaults.URL != "" {
+        return nil, c.errorf("defaults.url", "not allowed in defaults")
+    }
+    if c.Defaults.Scenario != nil {
+        return nil, c.errorf("defaults.scenario", "not allowed in defaults")
+    }
+    if len(c.Targets) == 0 {
+        return nil, c.errorf("targets", "no targets")
+    }
+    if _, err := c.retention(); err != nil {
+        return nil, err
+    }
+    if c.Listen != "" {
+        if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+            return nil, c.errorf("listen", "invalid address '%s' (use host:port or :port)", c.Listen)
+        }
+    }
+
+    notifiers, err := c.notifiers()
+    if err != nil {
+        return nil, err
+    }
+
+    names := map[string]string{}
+    var monitors []*Monitor
+    for i, t := range c.Targets {
+        key := fmt.Sprintf("targets[%d]", i)
+        m, err := c.monitor(key, t)
+        if err != nil {
+            return nil, err
+        }
+
+        nkey, notify := key + ".notify", t.Notify
+        if notify == nil {
+            nkey, notify = "defaults.notify", c.Defaults.Notify
+        }
+        for j, name := range notify {
+            nf, ok := notifiers[name]
+            if !ok {
+                return nil, c.errorf(fmt.Sprintf("%s[%d]", nkey, j), "unknown notifier '%s'", name)
+            }
+            m.Notifiers = append(m.Notifiers, nf)
+        }
+
+        if prev, ok := names[m.Name]; ok {
+            return nil, c.errorf(key + ".name", "duplicate name '%s' (see %s)", m.Name, prev)
+        }
+        names[m.Name] = key
+        monitors = append(monitors, m)
+    }
+    return monitors, nil
+}
+
+// notifiers returns a Notifier for each of the named notifiers.
+func (c *Config) notifiers() (map[string]Notifier, error) {
+
+    notifiers := map[string]Notifier{}
+    for name, n := range c.Notifiers {
+        key := "notifiers." + name
+        switch n.Type {
+        case "webhook":
+            u, err := url.Parse(n.URL)
+            if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+                return nil, c.errorf(key + ".url", "invalid URL '%s'", n.URL)
+            }
+            w := &WebhookNotifier{URL: n.URL}
+            for k, v := range n.Headers {
+                if w.Header == nil {
+                    w.Header = http.Header{}
+                }
+                w.Header.Set(k, v)
+            }
+            notifiers[name] = w
+        case "email":
+            if _, _, err := net.SplitHostPort(n.Server); err != nil {
+                return nil, c.errorf(key + ".server", "invalid SMTP server '%s' (use host:port)", n.Server)
+            }
+            if n.From == "" {
+                return nil, c.errorf(key, "missing from")
+            }
+            if len(n.To) == 0 {
+                return nil, c.errorf(key, "missing to")
+            }
+            notifiers[name] = &EmailNotifier{Server: n.Server, From: n.From, To: n.To,
+                                             Username: n.Username, Password: n.Password}
+        case "command":
+            if len(n.Command) == 0 || n.Command[0] == "" {
+                return nil, c.errorf(key, "missing command")
+            }
+            notifiers[name] = &CommandNotifier{Command: n.Command[0], Args: n.Command[1:]}
+        case "":
+            return nil, c.errorf(key, "missing type")
+        default:
+            return nil, c.errorf(key + ".type", "unknown type '%s' (use webhook, email or command)", n.Type)
+        }
+    }
+    return notifiers, nil
+}
+
+// Pool returns a Pool holding the Monitor for each target.
+func (c *Config) Pool() (*Pool, error) {
+
+    n := c.Concurrency
+    if n == 0 {
+        n = DefaultConcurrency
+    }
+    store, err := c.OpenStore()
+    if err != nil {
+        return nil, err
+    }
+    p := NewPool(n)
+    for _, m := range c.monitors {
+        m.Store = store
+        p.Add(m)
+    }
+    return p, nil
+}
+
+// OpenStore opens the store described by the configuration, or returns
+// nil if there is none.
+func (c *Config) OpenStore() (*Store, error) {
+
+    if c.Store == nil {
+        return nil, nil
+    }
+    retention, err := c.retention()
+    if err != nil {
+        return nil, err
+    }
+    store, err := OpenStore(c.Store.Dir, retention)
+    if err != nil {
+        return nil, c.errorf("store.dir", "%v", err)
+    }
+    return store, nil
+}
+
+func (c *Config) retention() (time.Duration, error) {
+
+    if c.Store == nil {
+        return 0, nil
+    }
+    if c.Store.Dir == "" {
+        return 0, c.errorf("store", "missing dir")
+    }
+    if c.Store.Retention == "" {
+        return 0, nil
+    }
+    d, err := time.ParseDuration(c.Store.Retention)
+    if err != nil || d < 0 {
+        return 0, c.errorf("store.retention", "invalid duration '%s'", c.Store.Retention)
+    }
+    return d, nil
+}
+
+func (c *Config) monitor(key string, t TargetConfig) (*Monitor, error) {
+
+    d := c.Defaults
+
+    if t.URL == "" && t.Scenario != nil && len(t.Scenario.Steps) > 0 {
+        first, err := expand(t.Scenario.Steps[0].URL, t.Scenario.Vars)
+        if err != nil {
+            return nil, c.errorf(key + ".scenario.steps[0].url", "%v", err)
+        }
+        t.URL = first                   // identifies the target
+    }
+    if t.URL == "" {
+        return nil, c.errorf(key, "missing url")
+    }
+    u, err := url.Parse(t.URL)
+    if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+        return nil, c.errorf(key + ".url", "invalid URL '%s'", t.URL)
+    }
+    m := NewMonitor(t.URL)
+    if t.Name != "" {
+        m.Name = t.Name
+    }
+
+    if m.Interval, err = c.duration(key, "interval", t.Interval, d.Interval, m.Interval); err != nil {
+        return nil, err
+    }
+    if m.Timeout, err = c.duration(key, "timeout", t.Timeout, d.Timeout, m.Timeout); err != nil {
+        return nil, err
+    }
+
+    vkey := key + ".protocol"
+    proto := t.Protocol
+    if proto == "" && d.Protocol != "" {
+        proto, vkey = d.Protocol, "defaults.protocol"
+    }
+    if m.Protocol, err = ParseProtocol(proto); err != nil {
+        return nil, c.errorf(vkey, "%v", err)
+    }
+    if err := m.Protocol.checkURL(u.Scheme); err != nil {
+        return nil, c.errorf(vkey, "%v", err)
+    }
+
+    vkey = key + ".variance"
+    switch {
+    case t.Variance != nil:
+        m.Variance = *t.Variance
+    case d.Variance != nil:
+        m.Variance = *d.Variance
+        vkey = "defaults.variance"
+    }
+    if m.Variance < 0 || m.Variance > 100 {
+        return nil, c.errorf(vkey, "variance must be between 0 and 100 percent")
+    }
+
+    vkey = key + ".alerting"
+    switch {
+    case t.Alerting != nil:
+        m.Policy = *t.Alerting
+    case d.Alerting != nil:
+        m.Policy = *d.Alerting
+        vkey = "defaults.alerting"
+    }
+    if err := m.Policy.Validate(); err != nil {
+        return nil, c.errorf(vkey, "%v", err)
+    }
+
+    vkey = key + ".redirects"
+    switch {
+    case t.Redirects != nil:
+        m.Redirects = *t.Redirects
+    case d.Redirects != nil:
+        m.Redirects = *d.Redirects
+        vkey = "defaults.redirects"
+    }
+    if err := m.Redirects.Validate(); err != nil {
+        return nil, c.errorf(vkey, "%v", err)
+    }
+
+    vkey = key + ".tls"
+    switch {
+    case t.TLS != nil:
+        m.TLS = *t.TLS
+    case d.TLS != nil:
+        m.TLS = *d.TLS
+        vkey = "defaults.tls"
+    }
+    if err := m.TLS.Validate(); err != nil {
+        return nil, c.errorf(vkey, "%v", err)
+    }
+
+    for _, l := range []struct {
+        name   string
+        t, d   map[string]string
+        limits *map[string]time.Duration
+    }{
+        {"phase_warning",  t.PhaseWarning,  d.PhaseWarning,  &m.PhaseWarning},
+        {"phase_critical", t.PhaseCritical, d.PhaseCritical, &m.PhaseCritical},
+    } {
+        vkey, limits := key + "." + l.name, l.t
+        if limits == nil {
+            vkey, limits = "defaults." + l.name, l.d
+        }
+        for phase, val := range limits {
+            if !knownPhase(phase) {
+                return nil, c.errorf(vkey + "." + phase, "unknown phase (use %s)", strings.Join(PhaseNames, ", "))
+            }
+            if val == "" {
+                return nil, c.errorf(vkey + "." + phase, "missing duration")
+            }
+            limit, err := c.duration(vkey, phase, val, "", 0)
+            if err != nil {
+                return nil, err
+            }
+            if *l.limits == nil {
+                *l.limits = map[string]time.Duration{}
+            }
+            (*l.limits)[phase] = limit
+        }
+    }
+
+    for _, b := range []struct {
+        name     string
+        t, d     *BaselineConfig
+        baseline *BaselineConfig
+    }{
+        {"time_baseline", t.TimeBaseline, d.TimeBaseline, &m.TimeBaseline},
+        {"size_baseline", t.SizeBaseline, d.SizeBaseline, &m.SizeBaseline},
+    } {
+        vkey = key + "." + b.name
+        switch {
+        case b.t != nil:
+            *b.baseline = *b.t
+        case b.d != nil:
+            *b.baseline = *b.d
+            vkey = "defaults." + b.name
+        }
+        if err := b.baseline.Validate(); err != nil {
+            return nil, c.errorf(vkey, "%v", err)
+        }
+    }
+
+    vkey = key + ".value"
+    switch {
+    case t.Value != nil:
+        m.Value = t.Value
+    case d.Value != nil:
+        m.Value = d.Value
+        vkey = "defaults.value"
+    }
+    if m.Value != nil {
+        if err := m.Value.Validate(); err != nil {
+            return nil, c.errorf(vkey, "%v", err)
+        }
+    }
+
+    vkey = key + ".assert"
+    switch {
+    case t.Assert != nil:
+        m.Assert = t.Assert
+    case d.Assert != nil:
+        m.Assert = d.Assert
+        vkey = "defaults.assert"
+    }
+    if m.Assert != nil {
+        if err := m.Assert.Validate(); err != nil {
+            return nil, c.errorf(vkey, "%v", err)
+        }
+    }
+
+    for k, v := range d.Headers {
+        if m.Header == nil {
+            m.Header = http.Header{}
+        }
+        m.Header.Set(k, v)
+    }
+    for k, v := range t.Headers {
+        if m.Header == nil {
+            m.Header = http.Header{}
+        }
+        m.Header.Set(k, v)
+    }
+
+    m.Method, m.UserAgent = t.Method, t.UserAgent
+    if m.Method == "" {
+        m.Method = d.Method
+    }
+    if m.UserAgent == "" {
+        m.UserAgent = d.UserAgent
+    }
+    for _, q := range []map[string]string{d.Query, t.Query} {
+        for k, v := range q {
+            if m.Query == nil {
+                m.Query = url.Values{}
+            }
+            m.Query.Set(k, v)
+        }
+    }
+
+    vkey = key + ".auth"
+    switch {
+    case t.Auth != nil:
+        m.Auth = t.Auth
+    case d.Auth != nil:
+        m.Auth = d.Auth
+        vkey = "defaults.auth"
+    }
+    if m.Auth != nil {
+        if err := m.Auth.Validate(); err != nil {
+            return nil, c.errorf(vkey, "%v", err)
+        }
+    }
+
+    vkey, body, file := key, t.Body, t.BodyFile
+    if body == "" && file == "" {
+        vkey, body, file = "defaults", d.Body, d.BodyFile
+    }
+    switch {
+    case body != "" && file != "":
+        return nil, c.errorf(vkey + ".body_file", "use either body or body_file, not both")
+    case body != "":
+        m.Body = []byte(body)
+    case file != "":
+        if m.Body, err = ioutil.ReadFile(file); err != nil {
+            return nil, c.errorf(vkey + ".body_file", "%v", err)
+        }
+    }
+
+    if err := m.validateRequest(); err != nil {
+        return nil, c.errorf(key, "%v", err)
+    }
+
+    if t.Scenario != nil {
+        if err := t.Scenario.Validate(); err != nil {
+            return nil, c.errorf(key + ".scenario", "%v", err)
+        }
+        m.Scenario = t.Scenario
+        if name := m.scenarioIgnores(); name != "" {
+            switch {
+            case name == "value" && t.Value == nil,
+                 name == "protocol" && t.Protocol == "",
+                 strings.HasPrefix(name, "redirects.") && t.Redirects == nil:
+                return nil, c.errorf("defaults." + name, "not used by a scenario (%s)", key)
+            }
+            return nil, c.errorf(key + "." + name, "not used by a scenario")
+        }
+    }
+
+    return m, nil
+}
+
+// duration parses a target setting, falling back to the default setting
+// and then to the built-in default.
+func (c *Config) duration(key, name, val, def string, builtin time.Duration) (time.Duration, error) {
+
+    if val != "" {
+        key = key + "." + name
+    } else if def != "" {
+        val, key = def, "defaults." + name
+    } else {
+        return builtin, nil
+    }
+    d, err := time.ParseDuration(val)
+    if err != nil {
+        return 0, c.errorf(key, "invalid duration '%s' (use values like 30s or 2m)", val)
+    }
+    if d <= 0 {
+        return 0, c.errorf(key, "must be greater than zero")
+    }
+    return d, nil
+}
+
+func (c *Config) errorf(key, format string, args ...interface{}) error {
+
+    line := 0
+    for k := key; k != ""; k = parentKey(k) {
+        if off, ok := c.offsets[k]; ok {
+            line = c.lineAt(off)
+            break
+        }
+    }
+    return &ConfigError{File: c.file, Line: line, Key: key, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (c *Config) lineAt(offset int64) int {
+
+    if offset > int64(len(c.data)) {
+        offset = int64(len(c.data))
+    }
+    return bytes.Count(c.data[:offset], []byte("\n")) + 1
+}
+
+// fieldKey converts the encoding/json form of a key ("targets.1.url")
+// to the form used in ConfigErrors ("targets[1].url").
+func fieldKey(field string) string {
+
+    var b bytes.Buffer
+    for i, part := range strings.Split(field, ".") {
+        if _, err := strconv.Atoi(part); err == nil {
+            b.WriteString("[" + part + "]")
+            continue
+        }
+        if i > 0 {
+            b.WriteString(".")
+        }
+        b.WriteString(part)
+    }
+    return b.String()
+}
+
+func parentKey(key string) string {
+
+    if i := strings.LastIndexAny(key, ".["); i >= 0 {
+        return key[:i]
+    }
+    return ""
+}
+
+// index walks the JSON document, recording where each key is found
+// and rejecting keys that do not correspond to a field of type t.
+func (c *Config) index(dec *json.Decoder, key string, t reflect.Type) error {
+
+    tok, err := dec.Token()
+    if err != nil {
+        return c.syntaxError(dec, err)
+    }
+    if key != "" {
+        if _, ok := c.offsets[key]; !ok {
+            c.offsets[key] = dec.InputOffset()
+        }
+    }
+    for t != nil && t.Kind() == reflect.Ptr {
+        t = t.Elem()
+    }
+
+    switch tok {
+    case json.Delim('{'):
+        for dec.More() {
+            tok, err := dec.Token()
+            if err != nil {
+                return c.syntaxError(dec, err)
+            }
+            name := tok.(string)
+            child := name
+            if key != "" {
+                child = key + "." + name
+            }
+            c.offsets[child] = dec.InputOffset()
+
+            var ft reflect.Type
+            if t != nil {
+                switch t.Kind() {
+                case reflect.Struct:
+                    f, ok := fieldByTag(t, name)
+                    if !ok {
+                        return c.errorf(child, "unknown key")
+                    }
+                    ft = f.Type
+                case reflect.Map:
+                    ft = t.Elem()
+                }
+            }
+            if err := c.index(dec, child, ft); err != nil {
+                return err
+            }
+        }
+        _, err = dec.Token()
+    case json.Delim('['):
+        for i := 0; dec.More(); i++ {
+            var et reflect.Type
+            if t != nil && t.Kind() == reflect.Slice {
+                et = t.Elem()
+            }
+            if err := c.index(dec, fmt.Sprintf("%s[%d]", key, i), et); err != nil {
+                return err
+            }
+        }
+        _, err = dec.Token()
+    }
+    if err != nil {
+        return c.syntaxError(dec, err)
+    }
+    return nil
+}
+
+func (c *Config) syntaxError(dec *json.Decoder, err error) error {
+
+    if se, ok := err.(*json.SyntaxError); ok {
+        return &ConfigError{File: c.file, Line: c.lineAt(se.Offset), Msg: se.Error()}
+    }
+    return &ConfigError{File: c.file, Line: c.lineAt(dec.InputOffset()), Msg: err.Error()}
+}
+
+func fieldByTag(t reflect.Type, name string) (reflect.StructField, bool) {
+
+    for i := 0; i < t.NumField(); i++ {
+        f := t.Field(i)
+        if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag != "" && tag == name {
+            return f, true
+        }
+    }
+    return reflect.StructField{}, false
+}
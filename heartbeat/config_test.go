@@ -0,0 +1,238 @@
+package heartbeat
+
+import (
+    "errors"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestConfigErrorLines(t *testing.T) {
+
+    for _, tc := range []struct {
+        name   string
+        config string
+        line   int
+        key    string
+        msg    string
+    }{
+        {"unknown key", `{
+    "targets": [
+        { "url": "http://localhost/",
+          "intervall": "5m" }
+    ]
+}`, 4, "targets[0].intervall", "unknown key"},
+
+        {"unknown nested key", `{
+    "defaults": {
+        "alerting": {
+            "failures": 2,
+            "flaps": 3
+        }
+    },
+    "targets": [ { "url": "http://localhost/" } ]
+}`, 5, "defaults.alerting.flaps", "unknown key"},
+
+        {"invalid duration", `{
+    "targets": [
+        { "url": "http://localhost/" },
+        { "url": "http://localhost/api",
+          "timeout": "10 seconds" }
+    ]
+}`, 5, "targets[1].timeout", "invalid duration '10 seconds'"},
+
+        {"invalid default duration", `{
+    "defaults": {
+        "interval": "-1m"
+    },
+    "targets": [ { "url": "http://localhost/" } ]
+}`, 3, "defaults.interval", "must be greater than zero"},
+
+        {"wrong type", `{
+    "targets": [
+        { "url": "http://localhost/",
+
+          "variance": "5" }
+    ]
+}`, 5, "targets[0].variance", "expected int, found string"},
+
+        {"syntax error", `{
+    "targets": [
+        { "url": "http://localhost/" }
+        { "url": "http://localhost/api" }
+    ]
+}`, 4, "", "invalid character '{'"},
+
+        {"missing url uses the target's line", `{
+    "targets": [
+        { "url": "http://localhost/" },
+        {
+          "name": "nowhere" }
+    ]
+}`, 4, "targets[1]", "missing url"},
+
+        {"invalid url", `{
+    "targets": [
+        { "name": "ftp",
+          "url": "ftp://localhost/" }
+    ]
+}`, 4, "targets[0].url", "invalid URL"},
+
+        {"duplicate name", `{
+    "targets": [
+        { "name": "home", "url": "http://localhost/" },
+        { "name": "home",
+          "url": "http://localhost/other" }
+    ]
+}`, 4, "targets[1].name", "duplicate name 'home' (see targets[0])"},
+
+        {"unknown notifier", `{
+    "targets": [
+        { "url": "http://localhost/",
+          "notify": [ "ops",
+                      "pager" ] }
+    ],
+    "notifiers": {
+        "ops": { "type": "command", "command": [ "true" ] }
+    }
+}`, 5, "targets[0].notify[1]", "unknown notifier 'pager'"},
+
+        {"name in defaults", `{
+    "defaults": {
+        "timeout": "5s",
+        "name": "everything"
+    },
+    "targets": [ { "url": "http://localhost/" } ]
+}`, 4, "defaults.name", "not allowed in defaults"},
+
+        {"scenario in defaults", `{
+    "defaults": {
+        "scenario": { "steps": [ { "url": "http://localhost/" } ] }
+    },
+    "targets": [ { "url": "http://localhost/" } ]
+}`, 3, "defaults.scenario", "not allowed in defaults"},
+
+        {"value with a scenario", `{
+    "targets": [
+        { "name": "checkout",
+          "value": { "jsonpath": "$.price", "below": 1 },
+          "scenario": { "steps": [ { "url": "http://localhost/" } ] } }
+    ]
+}`, 4, "targets[0].value", "not used by a scenario"},
+
+        {"protocol with a scenario", `{
+    "targets": [
+        { "name": "checkout", "protocol": "HTTP/1.1",
+          "scenario": { "steps": [ { "url": "http://localhost/" } ] } }
+    ]
+}`, 3, "targets[0].protocol", "not used by a scenario"},
+
+        {"expected redirects with a scenario", `{
+    "targets": [
+        { "name": "checkout",
+          "redirects": { "max": 2,
+                         "expect": [ "http://localhost/login" ] },
+          "scenario": { "steps": [ { "url": "http://localhost/" } ] } }
+    ]
+}`, 5, "targets[0].redirects.expect", "not used by a scenario"},
+
+        {"default protocol with a scenario", `{
+    "defaults": { "protocol": "h2c" },
+    "targets": [
+        { "url": "http://localhost/" },
+        { "scenario": { "steps": [ { "url": "http://localhost/" } ] } }
+    ]
+}`, 2, "defaults.protocol", "not used by a scenario (targets[1])"},
+
+        {"unknown phase", `{
+    "targets": [
+        { "url": "http://localhost/",
+          "phase_warning": { "tls": "200ms",
+                             "handshake": "1s" } }
+    ]
+}`, 5, "targets[0].phase_warning.handshake", "unknown phase"},
+
+        {"invalid default phase limit", `{
+    "defaults": {
+        "phase_critical": { "wait": "soon" }
+    },
+    "targets": [ { "url": "http://localhost/" } ]
+}`, 3, "defaults.phase_critical.wait", "invalid duration 'soon'"},
+
+        {"no targets", `{
+    "concurrency": 2,
+    "targets": [
+    ]
+}`, 3, "targets", "no targets"},
+    } {
+        _, err := ParseConfig("test.json", []byte(tc.config))
+        var ce *ConfigError
+        if !errors.As(err, &ce) {
+            t.Errorf("%s: error = %v, want a ConfigError", tc.name, err)
+            continue
+        }
+        if ce.File != "test.json" || ce.Line != tc.line || ce.Key != tc.key || !strings.Contains(ce.Msg, tc.msg) {
+            t.Errorf("%s: got %q (line %d, key %q), want line %d, key %q, %q",
+                     tc.name, ce.Error(), ce.Line, ce.Key, tc.line, tc.key, tc.msg)
+        }
+    }
+}
+
+func TestConfigPhaseLimits(t *testing.T) {
+
+    c, err := ParseConfig("test.json", []byte(`{
+        "defaults": { "phase_warning": { "tls": "200ms" } },
+        "targets": [
+            { "name": "a", "url": "http://localhost/a" },
+            { "name": "b", "url": "http://localhost/b",
+              "phase_warning": { "wait": "1s" }, "phase_critical": { "total": "5s" } }
+        ]
+    }`))
+    if err != nil {
+        t.Fatal(err)
+    }
+    monitors := c.Monitors()
+    a, b := monitors[0], monitors[1]
+    if len(a.PhaseWarning) != 1 || a.PhaseWarning["tls"] != 200 * time.Millisecond || a.PhaseCritical != nil {
+        t.Errorf("a: warning %v, critical %v", a.PhaseWarning, a.PhaseCritical)
+    }
+    if len(b.PhaseWarning) != 1 || b.PhaseWarning["wait"] != time.Second || b.PhaseCritical["total"] != 5 * time.Second {
+        t.Errorf("b: warning %v, critical %v", b.PhaseWarning, b.PhaseCritical)
+    }
+}
+
+func TestConfigErrorString(t *testing.T) {
+
+    for _, tc := range []struct {
+        err  ConfigError
+        want string
+    }{
+        {ConfigError{File: "w.json", Line: 7, Key: "targets[0].url", Msg: "missing"}, "w.json:7: targets[0].url: missing"},
+        {ConfigError{File: "w.json", Key: "listen", Msg: "bad"},                      "w.json: listen: bad"},
+        {ConfigError{File: "w.json", Line: 2, Msg: "unexpected EOF"},                 "w.json:2: unexpected EOF"},
+    } {
+        if got := tc.err.Error(); got != tc.want {
+            t.Errorf("Error() = %q, want %q", got, tc.want)
+        }
+    }
+}
+
+func TestFieldKey(t *testing.T) {
+
+    for _, tc := range []struct {
+        field, key, parent string
+    }{
+        {"targets.1.url",           "targets[1].url",           "targets[1]"},
+        {"targets.0.notify.2",      "targets[0].notify[2]",     "targets[0].notify"},
+        {"defaults.alerting",       "defaults.alerting",        "defaults"},
+        {"concurrency",             "concurrency",              ""},
+    } {
+        key := fieldKey(tc.field)
+        if key != tc.key {
+            t.Errorf("fieldKey(%q) = %q, want %q", tc.field, key, tc.key)
+        }
+        if p := parentKey(key); p != tc.parent {
+            t.Errorf("parentKey(%q) = %q, want %q", key, p, tc.parent)
+        }
+    }
+}
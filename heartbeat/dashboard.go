@@ -0,0 +1,258 @@
+package heartbeat
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "html/template"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+)
+
+// DefaultHistory is the number of results per target kept by a Dashboard.
+const DefaultHistory = 60
+
+// Dashboard collects the results of checks and serves, as an
+// http.Handler, a self-contained HTML status page for every target:
+// its state, the latest response time and size against the baseline,
+// a sparkline of recent response times and the recent alerts. The page
+// refreshes itself every few seconds. The same information is served
+// as JSON at status.json.
+//
+// A Dashboard may be shared by any number of Monitors.
+type Dashboard struct {
+    History int                 // results kept per target (0 = DefaultHistory)
+
+    mu      sync.Mutex
+    targets []*dashTarget       // in the order added
+}
+
+type dashTarget struct {
+    Name     string         `json:"name"`
+    URL      string         `json:"url"`
+    State    string         `json:"state"`
+    Flapping bool           `json:"flapping"`
+    Last     *dashPoint     `json:"last,omitempty"`
+    Baseline Baseline       `json:"baseline"`
+    Recent   []dashPoint    `json:"recent"`        // oldest first
+    Alerts   []dashAlert    `json:"alerts"`        // newest first
+}
+
+type dashPoint struct {
+    Time   time.Time     `json:"time"`
+    Millis float64       `json:"ms"`               // round trip time
+    Bytes  int64         `json:"bytes"`
+    Code   int           `json:"status_code"`
+    Error  ErrorCategory `json:"error,omitempty"`
+}
+
+type dashAlert struct {
+    Time    time.Time `json:"time"`
+    Kind    AlertKind `json:"kind"`
+    Message string    `json:"message"`
+    Notice  bool      `json:"notice"`
+}
+
+const dashAlerts = 10       // kept per target
+
+// NewDashboard returns an empty Dashboard.
+func NewDashboard() *Dashboard {
+
+    return &Dashboard{History: DefaultHistory}
+}
+
+// Add shows the Monitor's target on the dashboard before its first check.
+func (d *Dashboard) Add(m *Monitor) {
+
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    d.target(m)
+}
+
+func (d *Dashboard) target(m *Monitor) *dashTarget {
+
+    for _, t := range d.targets {
+        if t.Name == m.Name {
+            return t
+        }
+    }
+    t := &dashTarget{Name: m.Name, URL: m.URL, State: StatusUnknown.String()}
+    d.targets = append(d.targets, t)
+    return t
+}
+
+// Observe records the result of a check of the Monitor's target.
+func (d *Dashboard) Observe(m *Monitor, res *Result) {
+
+    baseline := m.Baseline()
+
+    d.mu.Lock()
+    defer d.mu.Unlock()
+
+    t := d.target(m)
+    p := dashPoint{
+        Time:   res.Start,
+        Millis: float64(res.Trip) / float64(time.Millisecond),
+        Bytes:  res.Bytes,
+        Code:   res.StatusCode,
+        Error:  res.Category,
+    }
+    t.State, t.Flapping, t.Last, t.Baseline = res.State.String(), res.Flapping, &p, baseline
+
+    history := d.History
+    if history <= 0 {
+        history = DefaultHistory
+    }
+    t.Recent = append(t.Recent, p)
+    if len(t.Recent) > history {
+        t.Recent = append(t.Recent[:0], t.Recent[len(t.Recent) - history:]...)
+    }
+
+    var alerts []dashAlert
+    for _, a := range res.Alerts {
+        alerts = append(alerts, dashAlert{Time: a.Time, Kind: a.Kind, Message: a.Message})
+    }
+    for _, n := range res.Notices {
+        alerts = append(alerts, dashAlert{Time: n.Time, Kind: n.Kind, Message: n.Message, Notice: true})
+    }
+    t.Alerts = append(alerts, t.Alerts...)
+    if len(t.Alerts) > dashAlerts {
+        t.Alerts = t.Alerts[:dashAlerts]
+    }
+}
+
+// ServeHTTP serves the status page, or status.json.
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+    d.mu.Lock()
+    var page bytes.Buffer
+    var err error
+    if strings.HasSuffix(r.URL.Path, "/status.json") {
+        err = json.NewEncoder(&page).Encode(d.targets)
+        w.Header().Set("Content-Type", "application/json")
+    } else {
+        err = dashTemplate.Execute(&page, struct {
+            Now     time.Time
+            Targets []*dashTarget
+        }{time.Now(), d.targets})
+        w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    }
+    d.mu.Unlock()
+
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Cache-Control", "no-cache")
+    w.Write(page.Bytes())
+}
+
+// sparkline draws the recent response times as an inline SVG, with
+// failed checks marked in red.
+func sparkline(points []dashPoint) template.HTML {
+
+    const width, height = 240.0, 36.0
+    if len(points) == 0 {
+        return ""
+    }
+    max := 0.0
+    for _, p := range points {
+        if p.Error == ErrorNone && p.Millis > max {
+            max = p.Millis
+        }
+    }
+    if max == 0 {
+        max = 1
+    }
+    step := width / float64(DefaultHistory - 1)
+    if len(points) > DefaultHistory {
+        step = width / float64(len(points) - 1)
+    }
+
+    var line, marks bytes.Buffer
+    for i, p := range points {
+        x := float64(i) * step
+        if p.Error != ErrorNone {
+            fmt.Fprintf(&marks, `<circle cx="%.1f" cy="%.1f" r="2" fill="#d9534f"/>`, x, height - 2)
+            continue
+        }
+        y := height - 2 - (p.Millis / max) * (height - 4)
+        fmt.Fprintf(&line, "%.1f,%.1f ", x, y)
+    }
+    return template.HTML(fmt.Sprintf(
+        `<svg width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f"><polyline points="%s" fill="none" stroke="#337ab7" stroke-width="1.5"/>%s</svg>`,
+        width, height, width, height, strings.TrimSpace(line.String()), marks.String()))
+}
+
+// ago describes how long before now a time was.
+func ago(now, t time.Time) string {
+
+    d := now.Sub(t)
+    switch {
+    case d < time.Minute:
+        return fmt.Sprintf("%ds ago", int(d.Seconds()))
+    case d < time.Hour:
+        return fmt.Sprintf("%dm ago", int(d.Minutes()))
+    }
+    return fmt.Sprintf("%dh%02dm ago", int(d.Hours()), int(d.Minutes()) % 60)
+}
+
+var dashTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+    "sparkline": sparkline,
+    "ago":       ago,
+    "lower":     strings.ToLower,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>heartbeat</title>
+<style>
+body { font-family: sans-serif; margin: 1.5em; color: #333; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 0.4em 0.8em; border-bottom: 1px solid #ddd; vertical-align: top; }
+th { font-size: 0.85em; color: #777; }
+.state { font-weight: bold; color: #fff; padding: 0.2em 0.5em; border-radius: 3px; }
+.ok { background: #5cb85c; } .warning { background: #f0ad4e; } .critical { background: #d9534f; } .unknown { background: #999; }
+.url, .dim { color: #777; font-size: 0.85em; }
+ul { margin: 0; padding-left: 1em; font-size: 0.85em; }
+li.notice { color: #5cb85c; }
+</style>
+</head>
+<body>
+<h2>heartbeat</h2>
+<div id="targets">
+<table>
+<tr><th>state</th><th>target</th><th>last check</th><th>response time</th><th>size</th><th>recent response times</th><th>recent alerts</th></tr>
+{{range $t := .Targets}}
+<tr>
+<td><span class="state {{lower .State}}">{{.State}}</span>{{if .Flapping}}<br><span class="dim">flapping</span>{{end}}</td>
+<td>{{.Name}}{{if ne .Name .URL}}<br><span class="url">{{.URL}}</span>{{end}}</td>
+{{with .Last}}
+<td>{{ago $.Now .Time}}{{if .Code}}<br><span class="dim">HTTP {{.Code}}</span>{{end}}{{if .Error}}<br><span class="dim">{{.Error}}</span>{{end}}</td>
+<td>{{printf "%.0f" .Millis}} ms{{with $t.Baseline}}{{if .Trip}}<br><span class="dim">baseline {{.Time}} ms ({{.TimeLo}} - {{.TimeHi}})</span>{{end}}{{end}}</td>
+<td>{{.Bytes}} bytes{{with $t.Baseline}}{{if .Bytes}}<br><span class="dim">baseline {{.Bytes}} ({{.BytesLo}} - {{.BytesHi}})</span>{{end}}{{end}}</td>
+{{else}}
+<td class="dim">not yet</td><td></td><td></td>
+{{end}}
+<td>{{sparkline .Recent}}</td>
+<td><ul>{{range .Alerts}}<li{{if .Notice}} class="notice"{{end}} title="{{.Time.Format "2006-01-02 15:04:05"}}">{{ago $.Now .Time}}: {{.Message}}</li>{{else}}<span class="dim">none</span>{{end}}</ul></td>
+</tr>
+{{else}}
+<tr><td colspan="7" class="dim">No targets</td></tr>
+{{end}}
+</table>
+<p class="dim">Updated {{.Now.Format "2006-01-02 15:04:05 MST"}}</p>
+</div>
+<script>
+setInterval(function() {
+    fetch(location.href, {cache: "no-store"}).then(function(r) { return r.text(); }).then(function(html) {
+        var page = new DOMParser().parseFromString(html, "text/html");
+        document.getElementById("targets").innerHTML = page.getElementById("targets").innerHTML;
+    }).catch(function() {});
+}, 5000);
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,97 @@
+package heartbeat
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+func serveDashboard(t *testing.T, d *Dashboard, path string) (string, string) {
+
+    w := httptest.NewRecorder()
+    d.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+    if w.Code != 200 {
+        t.Fatalf("%s: HTTP %d: %s", path, w.Code, w.Body)
+    }
+    return w.Header().Get("Content-Type"), w.Body.String()
+}
+
+func TestDashboard(t *testing.T) {
+
+    d := &Dashboard{History: 3}
+    if _, page := serveDashboard(t, d, "/"); !strings.Contains(page, "No targets") {
+        t.Error("no 'No targets' without targets")
+    }
+
+    shop, api := NewMonitor("http://shop/"), NewMonitor("http://api/")
+    shop.Name = "<shop>"
+    d.Add(shop)
+    d.Add(api)
+
+    start := time.Now().Add(-time.Minute)
+    for i := 0; i < 12; i++ {
+        res := &Result{Name: api.Name, URL: api.URL, Start: start.Add(time.Duration(i) * time.Second),
+                       StatusCode: 200, Trip: time.Duration(10 + i) * time.Millisecond, Bytes: 100, State: StatusOK}
+        if i == 11 {
+            res.StatusCode, res.Err, res.Category, res.State = 0, errors.New("refused"), ErrorRefused, StatusCritical
+        }
+        res.alert(AlertTime, "alert %d", i)
+        d.Observe(api, res)
+    }
+
+    ct, page := serveDashboard(t, d, "/")
+    if !strings.HasPrefix(ct, "text/html") {
+        t.Errorf("content type %q", ct)
+    }
+    for _, want := range []string{"&lt;shop&gt;", "not yet", `class="state critical"`, "alert 11", "<svg", `fill="#d9534f"`} {
+        if !strings.Contains(page, want) {
+            t.Errorf("page is missing %s", want)
+        }
+    }
+    if strings.Contains(page, "<shop>") || strings.Contains(page, "alert 1<") {
+        t.Error("page has an unescaped name, or an alert beyond the last ten")
+    }
+
+    ct, status := serveDashboard(t, d, "/status.json")
+    if ct != "application/json" {
+        t.Errorf("content type %q", ct)
+    }
+    var targets []dashTarget
+    if err := json.Unmarshal([]byte(status), &targets); err != nil {
+        t.Fatal(err)
+    }
+    if len(targets) != 2 || targets[0].Name != "<shop>" || targets[0].State != "UNKNOWN" || targets[0].Last != nil {
+        t.Fatalf("targets %+v", targets)
+    }
+    a := targets[1]
+    if a.State != "CRITICAL" || a.Last == nil || a.Last.Error != ErrorRefused || len(a.Recent) != 3 || a.Recent[0].Millis != 19 {
+        t.Errorf("api %+v", a)
+    }
+    if len(a.Alerts) != dashAlerts || a.Alerts[0].Message != "alert 11" || a.Alerts[dashAlerts - 1].Message != "alert 2" {
+        t.Errorf("alerts %+v", a.Alerts)
+    }
+}
+
+func TestAgo(t *testing.T) {
+
+    now := time.Now()
+    for _, tc := range []struct {
+        d    time.Duration
+        want string
+    }{
+        {5 * time.Second,               "5s ago"},
+        {3 * time.Minute,               "3m ago"},
+        {26 * time.Hour + time.Minute,  "26h01m ago"},
+    } {
+        if got := ago(now, now.Add(-tc.d)); got != tc.want {
+            t.Errorf("ago(%v) = %q, want %q", tc.d, got, tc.want)
+        }
+    }
+    if s := fmt.Sprint(sparkline(nil)); s != "" {
+        t.Errorf("sparkline of nothing %q", s)
+    }
+}
@@ -0,0 +1,106 @@
+package heartbeat
+
+import (
+    "context"
+    "crypto/tls"
+    "crypto/x509"
+    "errors"
+    "io"
+    "net"
+    "net/http"
+    "net/url"
+    "syscall"
+)
+
+// ErrorCategory classifies why a fetch failed. The values are stable
+// and may be relied upon in alerts, logs and metrics.
+type ErrorCategory string
+
+const (
+    ErrorNone        ErrorCategory = ""
+    ErrorDNS         ErrorCategory = "dns"           // host name could not be resolved
+    ErrorRefused     ErrorCategory = "refused"       // connection refused
+    ErrorUnreachable ErrorCategory = "unreachable"   // host or network unreachable
+    ErrorReset       ErrorCategory = "reset"         // connection reset or closed early
+    ErrorTLS         ErrorCategory = "tls"           // handshake or certificate verification failed
+    ErrorTimeout     ErrorCategory = "timeout"       // no response within the timeout period
+    ErrorProtocol    ErrorCategory = "protocol"      // malformed or unexpected HTTP response
+    ErrorOther       ErrorCategory = "other"
+)
+
+// Classify returns the category of an error returned by a fetch, using
+// the error types of the net, net/url, crypto/tls and crypto/x509
+// packages (see netErrors.txt).
+func Classify(err error) ErrorCategory {
+
+    if err == nil {
+        return ErrorNone
+    }
+
+    var dnsErr  *net.DNSError
+    var addrErr *net.AddrError
+    if errors.As(err, &dnsErr) || errors.As(err, &addrErr) {
+        return ErrorDNS     // including lookups that timed out
+    }
+    if isTLSError(err) {
+        return ErrorTLS
+    }
+
+    switch {
+    case errors.Is(err, syscall.ECONNREFUSED):
+        return ErrorRefused
+    case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+        return ErrorUnreachable
+    case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNABORTED), errors.Is(err, syscall.EPIPE),
+         errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+        return ErrorReset
+    case errors.Is(err, context.DeadlineExceeded):
+        return ErrorTimeout
+    }
+
+    var netErr net.Error
+    if errors.As(err, &netErr) && netErr.Timeout() {
+        return ErrorTimeout
+    }
+
+    var protoErr *http.ProtocolError
+    if errors.As(err, &protoErr) {
+        return ErrorProtocol
+    }
+    var opErr *net.OpError
+    if errors.As(err, &opErr) {
+        return ErrorOther   // some other socket problem
+    }
+    var urlErr *url.Error
+    if errors.As(err, &urlErr) {
+        return ErrorProtocol    // the connection was fine, the HTTP exchange was not
+    }
+    return ErrorOther
+}
+
+func isTLSError(err error) bool {
+
+    var (
+        unknownAuthority x509.UnknownAuthorityError
+        hostname         x509.HostnameError
+        invalid          x509.CertificateInvalidError
+        verification     *tls.CertificateVerificationError
+        recordHeader     tls.RecordHeaderError
+        alert            tls.AlertError
+        pin              *PinError
+        op               *net.OpError
+    )
+    if errors.As(err, &op) && op.Op == "remote error" {
+        return true             // an alert from the server, as in "certificate required"
+    }
+    return errors.As(err, &unknownAuthority) || errors.As(err, &hostname) ||
+           errors.As(err, &invalid) || errors.As(err, &verification) ||
+           errors.As(err, &recordHeader) || errors.As(err, &alert) || errors.As(err, &pin)
+}
+
+// isUnreachable reports whether the category means the host could not
+// be reached at all, as opposed to reached but misbehaving.
+func (c ErrorCategory) isUnreachable() bool {
+
+    return c == ErrorDNS || c == ErrorRefused || c == ErrorUnreachable
+}
@@ -0,0 +1,102 @@
+package heartbeat
+
+import (
+    "context"
+    "crypto/tls"
+    "crypto/x509"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "os"
+    "syscall"
+    "testing"
+    "time"
+)
+
+func TestClassify(t *testing.T) {
+
+    get := func(err error) error {
+        return &url.Error{Op: "Get", URL: "http://localhost/", Err: err}
+    }
+    dial := func(errno syscall.Errno) error {
+        return get(&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", errno)})
+    }
+    for _, tc := range []struct {
+        err  error
+        want ErrorCategory
+    }{
+        {nil,                                                               ErrorNone},
+        {get(&net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "nowhere"}}), ErrorDNS},
+        {get(&net.DNSError{Err: "i/o timeout", Name: "slow", IsTimeout: true}), ErrorDNS},
+        {dial(syscall.ECONNREFUSED),                                        ErrorRefused},
+        {dial(syscall.EHOSTUNREACH),                                        ErrorUnreachable},
+        {dial(syscall.ENETUNREACH),                                         ErrorUnreachable},
+        {get(&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}), ErrorReset},
+        {get(io.EOF),                                                       ErrorReset},
+        {get(io.ErrUnexpectedEOF),                                          ErrorReset},
+        {get(context.DeadlineExceeded),                                     ErrorTimeout},
+        {get(fmt.Errorf("reading body: %w", context.DeadlineExceeded)),     ErrorTimeout},
+        {get(x509.UnknownAuthorityError{}),                                 ErrorTLS},
+        {get(x509.HostnameError{Host: "localhost"}),                        ErrorTLS},
+        {get(tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}), ErrorTLS},
+        {get(&net.OpError{Op: "remote error", Err: errors.New("tls: certificate required")}), ErrorTLS},
+        {get(&http.ProtocolError{ErrorString: "malformed"}),                ErrorProtocol},
+        {get(errors.New("net/http: HTTP/1.x transport connection broken")), ErrorProtocol},
+        {&net.OpError{Op: "write", Err: errors.New("broken")},              ErrorOther},
+        {errors.New("something else"),                                      ErrorOther},
+    } {
+        if got := Classify(tc.err); got != tc.want {
+            t.Errorf("Classify(%v) = %q, want %q", tc.err, got, tc.want)
+        }
+    }
+}
+
+// The categories of real failed fetches.
+func TestClassifyFetch(t *testing.T) {
+
+    closed := httptest.NewServer(http.NotFoundHandler())
+    closed.Close()
+
+    slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+        time.Sleep(500 * time.Millisecond)
+    }))
+    defer slow.Close()
+
+    untrusted := httptest.NewTLSServer(http.NotFoundHandler())
+    defer untrusted.Close()
+
+    hangUp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+        conn, _, err := w.(http.Hijacker).Hijack()
+        if err == nil {
+            conn.Close()
+        }
+    }))
+    defer hangUp.Close()
+
+    for _, tc := range []struct {
+        name string
+        url  string
+        want ErrorCategory
+    }{
+        {"refused",     closed.URL,    ErrorRefused},
+        {"timeout",     slow.URL,      ErrorTimeout},
+        {"tls",         untrusted.URL, ErrorTLS},
+        {"reset",       hangUp.URL,    ErrorReset},
+    } {
+        m := NewMonitor(tc.url)
+        m.Timeout = 100 * time.Millisecond
+        res, err := m.CheckOnce(context.Background())
+        if err != nil {
+            t.Fatal(err)
+        }
+        if res.Err == nil || res.Category != tc.want {
+            t.Errorf("%s: error %v (%s), want %s", tc.name, res.Err, res.Category, tc.want)
+        }
+    }
+}
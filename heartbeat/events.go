@@ -0,0 +1,210 @@
+package heartbeat
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io"
+    "log"
+    "sync"
+    "time"
+)
+
+// EventType identifies the type of an Event.
+type EventType string
+
+const (
+    EventCheckStarted   EventType = "check_started"
+    EventCheckCompleted EventType = "check_completed"
+    EventAlertRaised    EventType = "alert_raised"
+    EventAlertCleared   EventType = "alert_cleared"     // the target recovered
+    EventNotice         EventType = "notice"            // the target started or stopped flapping
+    EventError          EventType = "error"             // a failed fetch, or a failure of heartbeat itself
+)
+
+// Event is one line of an EventLog. The field names are stable; fields
+// that do not apply to an event are left out.
+type Event struct {
+    Time       string             `json:"time"`                  // RFC 3339, UTC
+    Event      EventType          `json:"event"`
+    Target     string             `json:"target"`
+    URL        string             `json:"url"`
+    StatusCode int                `json:"status_code,omitempty"`
+    Protocol   string             `json:"protocol,omitempty"`
+    Bytes      *int64             `json:"bytes,omitempty"`
+    Phases     map[string]float64 `json:"phases_ms,omitempty"`   // by the names of Result.Phases
+    Hops       []EventHop         `json:"hops,omitempty"`        // if there were redirects
+    Steps      []EventStep        `json:"steps,omitempty"`       // of a scenario
+    TLS        *EventTLS          `json:"tls,omitempty"`
+    Value      *float64           `json:"value,omitempty"`
+    State      string             `json:"state,omitempty"`       // of the target after the check
+    Flapping   bool               `json:"flapping,omitempty"`
+    Failing    int                `json:"consecutive_failures,omitempty"`
+    Suppressed []AlertKind        `json:"suppressed,omitempty"`  // alerts held back by the AlertPolicy
+    Kind       AlertKind          `json:"kind,omitempty"`
+    Category   ErrorCategory      `json:"category,omitempty"`
+    Message    string             `json:"message,omitempty"`
+    Error      string             `json:"error,omitempty"`
+}
+
+// EventHop is a Hop, as logged.
+type EventHop struct {
+    URL        string  `json:"url"`
+    StatusCode int     `json:"status_code,omitempty"`
+    Location   string  `json:"location,omitempty"`
+    Millis     float64 `json:"ms"`
+    DNSMillis  float64 `json:"dns_ms"`
+    Lookups    int     `json:"dns_lookups"`
+}
+
+// EventStep is the Result of a scenario step, as logged.
+type EventStep struct {
+    Name       string             `json:"name"`
+    URL        string             `json:"url"`
+    StatusCode int                `json:"status_code,omitempty"`
+    Phases     map[string]float64 `json:"phases_ms"`
+    Error      string             `json:"error,omitempty"`
+}
+
+// EventTLS is a TLSInfo, as logged.
+type EventTLS struct {
+    Version     string   `json:"version,omitempty"`
+    CipherSuite string   `json:"cipher_suite,omitempty"`
+    Subjects    []string `json:"subjects"`             // of the chain, the server's own first
+    Names       []string `json:"names,omitempty"`      // of the server's certificate (DNS and IP SANs)
+    Expires     string   `json:"expires,omitempty"`    // when the first certificate in the chain expires
+}
+
+// EventLog writes Events as JSON, one object per line, for machines
+// rather than people to read.
+//
+// An EventLog may be shared by any number of Monitors.
+type EventLog struct {
+    mu sync.Mutex
+    w  io.Writer
+}
+
+// NewEventLog returns an EventLog writing to w.
+func NewEventLog(w io.Writer) *EventLog {
+
+    return &EventLog{w: w}
+}
+
+// Log writes an event. A zero Time is set to now.
+func (l *EventLog) Log(e Event) error {
+
+    if e.Time == "" {
+        e.Time = timestamp(time.Now())
+    }
+    var line bytes.Buffer
+    enc := json.NewEncoder(&line)
+    enc.SetEscapeHTML(false)            // keep messages such as 'a -> b' readable
+    if err := enc.Encode(e); err != nil {
+        return err
+    }
+
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    _, err := l.w.Write(line.Bytes())
+    return err
+}
+
+func timestamp(t time.Time) string {
+
+    return t.UTC().Format(time.RFC3339Nano)
+}
+
+// event logs an event for the Monitor's target, if there is an EventLog.
+func (m *Monitor) event(e Event) {
+
+    if m.Events == nil {
+        return
+    }
+    e.Target, e.URL = m.Name, m.URL
+    if err := m.Events.Log(e); err != nil {
+        log.Printf("heartbeat: %s: unable to log event: %v", m.Name, err)
+    }
+}
+
+// logEvents logs the completion of a check, then its error and any
+// alerts and notices.
+func (m *Monitor) logEvents(res *Result) {
+
+    if m.Events == nil {
+        return
+    }
+    done := Event{
+        Time:       timestamp(res.Start.Add(res.Trip)),
+        Event:      EventCheckCompleted,
+        StatusCode: res.StatusCode,
+        Phases:     map[string]float64{},
+        Value:      res.Value,
+        State:      res.State.String(),
+        Flapping:   res.Flapping,
+        Failing:    res.Failing,
+        Category:   res.Category,
+    }
+    if res.StatusCode != 0 {
+        done.Protocol = fmt.Sprintf("HTTP/%d.%d", res.ProtoMajor, res.ProtoMinor)
+    }
+    if res.Err == nil {
+        n := res.Bytes
+        done.Bytes = &n
+    }
+    for _, p := range res.Phases() {
+        done.Phases[p.Name] = float64(p.Duration) / float64(time.Millisecond)
+    }
+    for _, step := range res.Steps {
+        e := EventStep{Name: step.Name, URL: step.URL, StatusCode: step.StatusCode, Phases: map[string]float64{}}
+        for _, p := range step.Phases() {
+            e.Phases[p.Name] = float64(p.Duration) / float64(time.Millisecond)
+        }
+        if step.Err != nil {
+            e.Error = step.Err.Error()
+        }
+        done.Steps = append(done.Steps, e)
+    }
+    for _, a := range res.Suppressed {
+        done.Suppressed = append(done.Suppressed, a.Kind)
+    }
+    if len(res.Chain()) > 0 {
+        ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+        for _, h := range res.Hops {
+            done.Hops = append(done.Hops, EventHop{URL: h.URL, StatusCode: h.StatusCode, Location: h.Location,
+                                                   Millis: ms(h.Time), DNSMillis: ms(h.DNS), Lookups: h.Lookups})
+        }
+    }
+    if info := res.TLSInfo; info != nil {
+        done.TLS = &EventTLS{Version: info.Version, CipherSuite: info.CipherSuite}
+        for i, c := range info.Certs {
+            done.TLS.Subjects = append(done.TLS.Subjects, c.Subject)
+            if i == 0 {
+                done.TLS.Names = append(append(done.TLS.Names, c.DNSNames...), c.IPs...)
+            }
+        }
+        if expires := info.Expires(); !expires.IsZero() {
+            done.TLS.Expires = timestamp(expires)
+        }
+    }
+    m.event(done)
+
+    if res.Err != nil {
+        m.event(Event{
+            Time:     done.Time,
+            Event:    EventError,
+            Category: res.Category,
+            Error:    res.Err.Error(),
+        })
+    }
+    for _, a := range res.Alerts {
+        m.event(Event{Time: timestamp(a.Time), Event: EventAlertRaised, State: done.State,
+                      Kind: a.Kind, Category: a.Category, Message: a.Message})
+    }
+    for _, n := range res.Notices {
+        e := Event{Time: timestamp(n.Time), Event: EventNotice, State: done.State, Kind: n.Kind, Message: n.Message}
+        if n.Kind == AlertRecovered {
+            e.Event = EventAlertCleared
+        }
+        m.event(e)
+    }
+}
@@ -0,0 +1,121 @@
+package heartbeat
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+// readEvents parses an event log, checking the time of each event.
+func readEvents(t *testing.T, log *bytes.Buffer) []Event {
+
+    var events []Event
+    scanner := bufio.NewScanner(log)
+    for scanner.Scan() {
+        var e Event
+        if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+            t.Fatalf("%s: %v", scanner.Bytes(), err)
+        }
+        if tm, err := time.Parse(time.RFC3339, e.Time); err != nil || !strings.HasSuffix(e.Time, "Z") || tm.IsZero() {
+            t.Errorf("%s event at %q, not RFC 3339 in UTC", e.Event, e.Time)
+        }
+        events = append(events, e)
+    }
+    return events
+}
+
+func TestEventsRun(t *testing.T) {
+
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+        w.Write([]byte("ok"))
+    }))
+    defer srv.Close()
+
+    var log bytes.Buffer
+    m := NewMonitor(srv.URL)
+    m.Name, m.Interval, m.Events = "local", time.Millisecond, NewEventLog(&log)
+    ctx, cancel := context.WithCancel(context.Background())
+    checks := 0
+    m.OnResult = func(res *Result) {
+        if checks++; checks == 2 {
+            cancel()
+        }
+    }
+    if err := m.Run(ctx); err != context.Canceled {
+        t.Fatal(err)
+    }
+
+    var types []EventType
+    for _, e := range readEvents(t, &log) {
+        types = append(types, e.Event)
+        if e.Target != "local" || e.URL != srv.URL {
+            t.Errorf("%s event for %q %q", e.Event, e.Target, e.URL)
+        }
+        if e.Event == EventCheckCompleted && (e.StatusCode != 200 || e.Protocol != "HTTP/1.1" || e.Bytes == nil || *e.Bytes != 2 ||
+                                              e.State != "OK" || e.Phases["total"] <= 0) {
+            t.Errorf("completed %+v", e)
+        }
+    }
+    want := []EventType{EventCheckStarted, EventCheckCompleted, EventCheckStarted, EventCheckCompleted}
+    if len(types) < len(want) {
+        t.Fatalf("events %q", types)
+    }
+    for i := range want {
+        if types[i] != want[i] {
+            t.Errorf("events %q, want %q first", types, want)
+            break
+        }
+    }
+}
+
+func TestLogEvents(t *testing.T) {
+
+    var log bytes.Buffer
+    m := NewMonitor("http://localhost/")
+    m.Events = NewEventLog(&log)
+
+    start := time.Date(2024, 3, 10, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+    res := &Result{Name: m.Name, URL: m.URL, Start: start, Trip: 1500 * time.Millisecond,
+                   Err: errors.New("connection refused"), Category: ErrorRefused, State: StatusCritical, Failing: 3}
+    res.alert(AlertError, "request failed (%s)", res.Category)
+    res.notice(AlertRecovered, "RECOVERED after 2 failed checks")
+    res.notice(AlertFlapping, "FLAPPING status changed 4 times")
+    m.logEvents(res)
+
+    events := readEvents(t, &log)
+    var types []EventType
+    for _, e := range events {
+        types = append(types, e.Event)
+    }
+    want := []EventType{EventCheckCompleted, EventError, EventAlertRaised, EventAlertCleared, EventNotice}
+    if len(types) != len(want) {
+        t.Fatalf("events %q, want %q", types, want)
+    }
+    for i := range want {
+        if types[i] != want[i] {
+            t.Fatalf("events %q, want %q", types, want)
+        }
+    }
+
+    done, failed, raised := events[0], events[1], events[2]
+    if done.Time != "2024-03-10T11:00:01.5Z" || done.Bytes != nil || done.Protocol != "" || done.Failing != 3 || done.Category != ErrorRefused {
+        t.Errorf("completed %+v", done)
+    }
+    if failed.Time != done.Time || failed.Error != "connection refused" || failed.Category != ErrorRefused {
+        t.Errorf("error %+v", failed)
+    }
+    if raised.Kind != AlertError || raised.Message != "request failed (refused)" || raised.State != "CRITICAL" {
+        t.Errorf("alert %+v", raised)
+    }
+    if events[3].Kind != AlertRecovered || events[4].Kind != AlertFlapping {
+        t.Errorf("notices %+v %+v", events[3], events[4])
+    }
+}
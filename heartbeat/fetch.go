@@ -0,0 +1,220 @@
+package heartbeat
+
+import (
+    "bytes"
+    "context"
+    "crypto/tls"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "net/http"
+    "net/http/httptrace"
+    "time"
+)
+
+// fetch requests the target, redirecting as necessary, and reads the
+// response body - timing each phase as it goes. It does not look at
+// (or change) the baseline, so any number of fetches may be in progress
+// at once.
+//
+// The response body is only returned if it is needed by the Monitor's
+// checks. The base RoundTripper defaults to the Monitor's own (which
+// is http.DefaultTransport unless a Protocol or TLS settings have been
+// specified).
+//
+// Errors from the request or while reading the body are reported in
+// the Result; the returned error is only non-nil if the fetch could not
+// be carried out at all.
+func (m *Monitor) fetch(ctx context.Context, base http.RoundTripper) (*Result, []byte, error) {
+
+    if base == nil {
+        var err error
+        if base, err = m.roundTripper(); err != nil {
+            return nil, nil, err
+        }
+    }
+    t := &transport{monitor: m, base: base}
+
+    req, err := m.newRequest(ctx)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    tStart := time.Now()
+    m.debugf("%s Starting HTTP %s now ...\n", tStart, req.Method)
+
+    res := &Result{Name: m.Name, URL: m.URL, Start: tStart}
+
+    var dnsTime,      connectTime          time.Time
+    var tlsTime,      connTime             time.Time
+    var wroteTime,    firstTime            time.Time
+    var totalDNStime, totalConnectionTime  time.Duration
+    var totalTLStime, totalWriteTime       time.Duration
+    var firstDNStime                       time.Duration
+    var connErr                            *ConnectError
+    var connected                          bool  // a dial succeeded, or a connection was reused
+
+    // Each phase is timed every time it happens (a redirect may need
+    // another lookup, connection and request), except for the wait for
+    // the response and its transfer, which are those of the final response.
+    //
+    // The hooks of a dial may be called from another goroutine, even once
+    // the fetch is over: the transport finishes a dial that has lost out to
+    // an idle connection, keeping it for later. So they all hold t.mu.
+    trace := &httptrace.ClientTrace {
+        DNSStart:        func(sinfo httptrace.DNSStartInfo) {
+            t.mu.Lock()
+            defer t.mu.Unlock()
+            dnsTime = time.Now()
+            m.debugf("DNS lookup started for '%v'\n", sinfo.Host); // doesn't seem to reflect redirects
+        },
+        DNSDone:         func(_ httptrace.DNSDoneInfo)  {
+            t.mu.Lock()
+            defer t.mu.Unlock()
+            dTime := time.Now().Sub(dnsTime)
+            totalDNStime += dTime
+            t.lookup(dTime)
+            if firstDNStime == 0 {
+                firstDNStime = dTime
+            }
+            m.debugf("DNS lookup took: %d ms\n", int(time.Duration(dTime) / time.Millisecond))
+        },
+        ConnectStart:    func(_, _ string) {
+            t.mu.Lock()
+            defer t.mu.Unlock()
+            connectTime = time.Now() // there can be many connections
+        },
+        ConnectDone:     func(net, addr string, err error) {
+            t.mu.Lock()
+            defer t.mu.Unlock()
+            if err != nil {
+                if connErr == nil {
+                    connErr = &ConnectError{Net: net, Addr: addr, Err: err}
+                }
+            } else {
+                connected = true
+                cTime := time.Now().Sub(connectTime)
+                totalConnectionTime += cTime
+                m.debugf("Connection:  %d ms\n", int(time.Duration(cTime) / time.Millisecond))
+            }
+        },
+        TLSHandshakeStart: func() {
+            t.mu.Lock()
+            defer t.mu.Unlock()
+            tlsTime = time.Now()
+        },
+        TLSHandshakeDone:  func(_ tls.ConnectionState, _ error) {
+            t.mu.Lock()
+            defer t.mu.Unlock()
+            hTime := time.Now().Sub(tlsTime)
+            totalTLStime += hTime
+            m.debugf("TLS handshake: %d ms\n", int(hTime / time.Millisecond))
+        },
+        GotConn:         func(info httptrace.GotConnInfo) {
+            t.mu.Lock()
+            defer t.mu.Unlock()
+            connTime  = time.Now()
+            connected = true
+            t.GotConn(info)
+        },
+        WroteRequest:    func(_ httptrace.WroteRequestInfo) {
+            t.mu.Lock()
+            defer t.mu.Unlock()
+            wroteTime = time.Now()
+            totalWriteTime += wroteTime.Sub(connTime)
+        },
+        GotFirstResponseByte: func() {
+            t.mu.Lock()
+            defer t.mu.Unlock()
+            firstTime = time.Now()      // the last of these is the final response
+            res.TTFB  = firstTime.Sub(tStart)
+            if !wroteTime.IsZero() {
+                res.Wait = firstTime.Sub(wroteTime)
+            }
+        },
+        Got100Continue:  t.Got100Continue,
+    }
+    req = req.WithContext(httptrace.WithClientTrace(ctx, trace))
+
+    client  := &http.Client {
+        Transport:     t,
+        Timeout:       m.Timeout,
+        CheckRedirect: m.Redirects.checkRedirect,
+        Jar:           m.jar,
+    }
+
+    resp, err := client.Do(req)
+    t.mu.Lock()
+    res.Hops     = append([]Hop(nil), t.hops...)
+    res.FirstDNS = firstDNStime
+    res.DNS      = totalDNStime
+    res.Connect  = totalConnectionTime
+    res.TLS      = totalTLStime
+    res.Write    = totalWriteTime
+    dialFailed  := connErr != nil && !connected
+    gotFirst    := !firstTime.IsZero()
+    t.mu.Unlock()
+    if err != nil {
+        res.Err  = err
+        // A failed dial is only the cause if no connection was made at
+        // all (another address may have been connected to instead) and
+        // the fetch did not time out dialling the rest.
+        if dialFailed && Classify(err) != ErrorTimeout {
+            res.Err = connErr   // more useful than the wrapped error
+        }
+        res.Category = Classify(res.Err)
+        res.Trip     = time.Since(tStart)
+        if res.Category == ErrorTLS {
+            res.TLSInfo = tlsErrorInfo(err)
+        }
+        return res, nil, nil
+    }
+    defer resp.Body.Close()
+
+    m.debugf("Total DNS lookup time was: %v ms (First DNS lookup time was: %d ms)\n",
+             int(res.DNS / time.Millisecond), int(res.FirstDNS / time.Millisecond))
+    m.debugf("Total connection time was: %v ms\n", int(res.Connect / time.Millisecond))
+    m.debugf("Total TLS handshake time was: %v ms, request writing %v ms, server processing %v ms\n",
+             int(res.TLS / time.Millisecond), int(res.Write / time.Millisecond), int(res.Wait / time.Millisecond))
+
+    res.StatusCode = resp.StatusCode
+    res.Status     = resp.Status
+    res.ProtoMajor = resp.ProtoMajor
+    res.ProtoMinor = resp.ProtoMinor
+    res.Header     = resp.Header
+    if resp.TLS != nil {
+        res.TLSInfo = newTLSInfo(resp.TLS)
+    }
+
+    var body []byte
+    if isRedirected(resp) {
+        res.Redirected = true
+        m.debugf("%s request was redirected with code %d\n", time.Now(), resp.StatusCode)
+    } else {
+        var w io.Writer = ioutil.Discard
+        var buf bytes.Buffer
+        if m.Value != nil || (m.Assert != nil && m.Assert.needsBody()) || m.keepBody {
+            w = &buf        // keep the body to check
+        }
+
+        res.Bytes, err = io.Copy(w, resp.Body)
+        if err != nil {
+            res.Err      = fmt.Errorf("failed to read response body: %w", err)
+            res.Category = Classify(err)
+            res.Trip     = time.Since(tStart)
+            return res, nil, nil
+        }
+        body = buf.Bytes()
+    }
+
+    res.Trip = time.Since(tStart)
+    if gotFirst {
+        res.Transfer = res.Trip - res.TTFB
+    }
+    return res, body, nil
+}
+
+func isRedirected(resp *http.Response) bool {
+
+    return resp.StatusCode > 299 && resp.StatusCode < 400
+}
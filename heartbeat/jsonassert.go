@@ -0,0 +1,246 @@
+package heartbeat
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io"
+    "strings"
+)
+
+// A jsonAssertion is a test of the value at a JSON path, written as
+//
+//    $.status == "ok"
+//    $.db.latency_ms < 100
+//    $.items.length() >= 1
+//    $.db exists
+//    $.error !exists
+//    $.version type string
+//
+// The operators are ==, !=, <, <=, >, >= (numbers only), exists,
+// !exists and type (string, number, boolean, object, array or null).
+// A path on its own means exists. Values are JSON (as in "ok", 12,
+// true or null), although strings may also be single quoted or, if
+// they are a single word, not quoted at all.
+type jsonAssertion struct {
+    expr  string
+    steps []jsonStep
+    op    string
+    value interface{}       // as decoded with UseNumber
+}
+
+var jsonTypes = []string{"string", "number", "boolean", "object", "array", "null"}
+
+// parseJSONAssertion parses an assertion such as '$.db.latency_ms < 100'.
+func parseJSONAssertion(expr string) (*jsonAssertion, error) {
+
+    s := strings.TrimSpace(expr)
+    end := jsonPathEnd(s)
+    a := &jsonAssertion{expr: s}
+    var err error
+    if a.steps, err = parseJSONPath(s[:end]); err != nil {
+        return nil, err
+    }
+    rest := strings.TrimSpace(s[end:])
+    if rest == "" {
+        a.op = "exists"
+        return a, nil
+    }
+
+    for _, op := range []string{"==", "!=", "<=", ">=", "<", ">", "!exists", "exists", "type"} {
+        if strings.HasPrefix(rest, op) {
+            a.op, rest = op, strings.TrimSpace(rest[len(op):])
+            break
+        }
+    }
+    switch {
+    case a.op == "":
+        return nil, fmt.Errorf("invalid JSON assertion '%s': unknown operator", expr)
+    case a.op == "exists" || a.op == "!exists":
+        if rest != "" {
+            return nil, fmt.Errorf("invalid JSON assertion '%s': %s takes no value", expr, a.op)
+        }
+        return a, nil
+    case rest == "":
+        return nil, fmt.Errorf("invalid JSON assertion '%s': missing value", expr)
+    case a.op == "type":
+        for _, t := range jsonTypes {
+            if rest == t {
+                a.value = t
+                return a, nil
+            }
+        }
+        return nil, fmt.Errorf("invalid JSON assertion '%s': unknown type '%s' (use %s)", expr, rest, strings.Join(jsonTypes, ", "))
+    }
+
+    if a.value, err = parseJSONValue(rest); err != nil {
+        return nil, fmt.Errorf("invalid JSON assertion '%s': %v", expr, err)
+    }
+    if _, ok := a.value.(json.Number); !ok && a.op != "==" && a.op != "!=" {
+        return nil, fmt.Errorf("invalid JSON assertion '%s': %s needs a number", expr, a.op)
+    }
+    return a, nil
+}
+
+// jsonPathEnd returns the length of the JSON path at the start of s,
+// which ends at the first space or operator outside brackets.
+func jsonPathEnd(s string) int {
+
+    var quote byte
+    depth := 0
+    for i := 0; i < len(s); i++ {
+        c := s[i]
+        switch {
+        case quote != 0:
+            if c == quote {
+                quote = 0
+            }
+        case depth > 0 && (c == '\'' || c == '"'):
+            quote = c
+        case c == '[':
+            depth++
+        case c == ']':
+            depth--
+        case depth == 0 && strings.IndexByte(" \t=!<>", c) >= 0:
+            return i
+        }
+    }
+    return len(s)
+}
+
+// parseJSONValue parses the value of an assertion.
+func parseJSONValue(s string) (interface{}, error) {
+
+    if len(s) >= 2 && s[0] == '\'' && s[len(s) - 1] == '\'' {
+        return s[1:len(s) - 1], nil
+    }
+    dec := json.NewDecoder(strings.NewReader(s))
+    dec.UseNumber()
+    var v interface{}
+    if err := dec.Decode(&v); err == nil {
+        if _, err := dec.Token(); err == io.EOF {  // nothing follows the value
+            return v, nil
+        }
+    }
+    if strings.ContainsAny(s, " \t\"'") {
+        return nil, fmt.Errorf("invalid value '%s' (quote strings)", s)
+    }
+    return s, nil
+}
+
+// check tests the assertion against a decoded JSON document, returning
+// a description of the failure (including the value found), if any.
+func (a *jsonAssertion) check(doc interface{}) string {
+
+    v, err := evalJSONPath(doc, a.steps)
+    if err != nil {
+        if a.op == "!exists" {
+            return ""
+        }
+        return fmt.Sprintf("%s (%v)", a.expr, err)
+    }
+
+    ok := false
+    switch a.op {
+    case "exists":
+        ok = true
+    case "!exists":
+        ok = false
+    case "type":
+        ok = jsonType(v) == a.value
+        return failed(ok, fmt.Sprintf("%s (is %s)", a.expr, jsonType(v)))
+    case "==", "!=":
+        ok = jsonEqual(v, a.value) == (a.op == "==")
+    default:
+        n, isNum := v.(json.Number)
+        if !isNum {
+            return fmt.Sprintf("%s (%s is not a number)", a.expr, jsonText(v))
+        }
+        x, _ := n.Float64()
+        y, _ := a.value.(json.Number).Float64()
+        switch a.op {
+        case "<":
+            ok = x < y
+        case "<=":
+            ok = x <= y
+        case ">":
+            ok = x > y
+        case ">=":
+            ok = x >= y
+        }
+    }
+    return failed(ok, fmt.Sprintf("%s (actual %s)", a.expr, jsonText(v)))
+}
+
+func failed(ok bool, problem string) string {
+
+    if ok {
+        return ""
+    }
+    return problem
+}
+
+func jsonType(v interface{}) string {
+
+    switch v.(type) {
+    case string:
+        return "string"
+    case json.Number:
+        return "number"
+    case bool:
+        return "boolean"
+    case map[string]interface{}:
+        return "object"
+    case []interface{}:
+        return "array"
+    }
+    return "null"
+}
+
+// jsonEqual compares two decoded values, numbers by value (so 1 == 1.0).
+func jsonEqual(x, y interface{}) bool {
+
+    if nx, ok := x.(json.Number); ok {
+        ny, ok := y.(json.Number)
+        if !ok {
+            return false
+        }
+        fx, errx := nx.Float64()
+        fy, erry := ny.Float64()
+        if errx != nil || erry != nil {
+            return nx == ny
+        }
+        return fx == fy
+    }
+    bx, _ := json.Marshal(x)
+    by, _ := json.Marshal(y)
+    return bytes.Equal(bx, by)
+}
+
+// jsonText returns a value as (abbreviated) JSON.
+func jsonText(v interface{}) string {
+
+    b, err := json.Marshal(v)
+    if err != nil {
+        return fmt.Sprint(v)
+    }
+    return truncate(string(b), 60)
+}
+
+// checkJSON decodes a body and tests each of the assertions against it.
+func checkJSON(assertions []*jsonAssertion, body []byte) []string {
+
+    dec := json.NewDecoder(bytes.NewReader(body))
+    dec.UseNumber()
+    var doc interface{}
+    if err := dec.Decode(&doc); err != nil {
+        return []string{fmt.Sprintf("body is not JSON: %v", err)}
+    }
+    var problems []string
+    for _, a := range assertions {
+        if p := a.check(doc); p != "" {
+            problems = append(problems, p)
+        }
+    }
+    return problems
+}
@@ -0,0 +1,157 @@
+package heartbeat
+
+import (
+    "encoding/json"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestJSONPathEnd(t *testing.T) {
+
+    for _, tc := range []struct {
+        s    string
+        path string
+    }{
+        {"$.status",                     "$.status"},
+        {"$.status == \"ok\"",           "$.status"},
+        {"$.latency<100",                "$.latency"},
+        {"$.error!exists",               "$.error"},
+        {"$['a b'] == 1",                "$['a b']"},
+        {"$['x==y'] exists",             "$['x==y']"},
+        {`$["]"] == 1`,                  `$["]"]`},
+        {"$.items[0].value >= 2",        "$.items[0].value"},
+        {"$.items.length()\t> 0",        "$.items.length()"},
+    } {
+        if end := jsonPathEnd(tc.s); tc.s[:end] != tc.path {
+            t.Errorf("jsonPathEnd(%q) gives %q, want %q", tc.s, tc.s[:end], tc.path)
+        }
+    }
+}
+
+func TestParseJSONAssertion(t *testing.T) {
+
+    for _, tc := range []struct {
+        expr  string
+        op    string
+        value interface{}
+        err   string
+    }{
+        {"$.status == \"ok\"",         "==",      "ok",                 ""},
+        {"$.status == 'all good'",     "==",      "all good",           ""},
+        {"$.status == ok",             "==",      "ok",                 ""},
+        {"$.status!=\"down\"",         "!=",      "down",               ""},
+        {"$.db.latency_ms < 100",      "<",       json.Number("100"),   ""},
+        {"$.db.latency_ms <= 1.5",     "<=",      json.Number("1.5"),   ""},
+        {"$.items.length() >= 1",      ">=",      json.Number("1"),     ""},
+        {"$.n > -3",                   ">",       json.Number("-3"),    ""},
+        {"$.up == true",               "==",      true,                 ""},
+        {"$.err == null",              "==",      nil,                  ""},
+        {"$.tags == [\"a\"]",          "==",      []interface{}{"a"},   ""},
+        {"$.db exists",                "exists",  nil,                  ""},
+        {"$.db",                       "exists",  nil,                  ""},
+        {"$.error !exists",            "!exists", nil,                  ""},
+        {"$.version type string",      "type",    "string",             ""},
+        {"$.items type array",         "type",    "array",              ""},
+        {"$.status ~ ok",              "",        nil, "unknown operator"},
+        {"$.status == ",               "",        nil, "missing value"},
+        {"$.db exists 1",              "",        nil, "exists takes no value"},
+        {"$.db !exists x",             "",        nil, "!exists takes no value"},
+        {"$.version type text",        "",        nil, "unknown type 'text'"},
+        {"$.version type",             "",        nil, "missing value"},
+        {"$.latency < fast",           "",        nil, "< needs a number"},
+        {"$.latency > true",           "",        nil, "> needs a number"},
+        {"$.status == all good",       "",        nil, "quote strings"},
+        {"$.status == \"ok",           "",        nil, "quote strings"},
+        {"$.status == {\"a\":",        "",        nil, "quote strings"},
+        {"$..status == 1",             "",        nil, "empty key"},
+        {"$.items[0 == 1",             "",        nil, "missing ']'"},
+    } {
+        a, err := parseJSONAssertion(tc.expr)
+        if tc.err != "" {
+            if err == nil || !strings.Contains(err.Error(), tc.err) {
+                t.Errorf("parseJSONAssertion(%q) error = %v, want %q", tc.expr, err, tc.err)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("parseJSONAssertion(%q): %v", tc.expr, err)
+            continue
+        }
+        if a.op != tc.op || !reflect.DeepEqual(a.value, tc.value) {
+            t.Errorf("parseJSONAssertion(%q) = %s %#v, want %s %#v", tc.expr, a.op, a.value, tc.op, tc.value)
+        }
+    }
+}
+
+func TestJSONAssertionCheck(t *testing.T) {
+
+    doc := decodeTestJSON(t, `{
+        "status": "ok",
+        "up":     true,
+        "db":     {"latency_ms": 42, "errors": 0},
+        "items":  [1, 2, 3],
+        "ratio":  1.0,
+        "error":  null
+    }`)
+    for _, tc := range []struct {
+        expr    string
+        problem string     // "" if the assertion holds
+    }{
+        {"$.status == \"ok\"",         ""},
+        {"$.status == ok",             ""},
+        {"$.status != 'down'",         ""},
+        {"$.status == \"down\"",       `$.status == "down" (actual "ok")`},
+        {"$.up == true",               ""},
+        {"$.ratio == 1",               ""},
+        {"$.db.latency_ms < 100",      ""},
+        {"$.db.latency_ms <= 42",      ""},
+        {"$.db.latency_ms > 42",       "$.db.latency_ms > 42 (actual 42)"},
+        {"$.db.errors >= 1",           "$.db.errors >= 1 (actual 0)"},
+        {"$.items.length() == 3",      ""},
+        {"$.items[0] == \"1\"",        `$.items[0] == "1" (actual 1)`},
+        {"$.status < 1",               `$.status < 1 ("ok" is not a number)`},
+        {"$.db exists",                ""},
+        {"$.error exists",             ""},
+        {"$.cache exists",             "$.cache exists ($.cache not found)"},
+        {"$.cache !exists",            ""},
+        {"$.db !exists",               "$.db !exists (actual {\"errors\":0,\"latency_ms\":42})"},
+        {"$.db type object",           ""},
+        {"$.error type null",          ""},
+        {"$.items type object",        "$.items type object (is array)"},
+        {"$.db.latency_ms.x == 1",     "$.db.latency_ms.x == 1 ($.db.latency_ms is not an object)"},
+    } {
+        a, err := parseJSONAssertion(tc.expr)
+        if err != nil {
+            t.Errorf("parseJSONAssertion(%q): %v", tc.expr, err)
+            continue
+        }
+        if got := a.check(doc); got != tc.problem {
+            t.Errorf("%s: got %q, want %q", tc.expr, got, tc.problem)
+        }
+    }
+}
+
+func TestCheckJSON(t *testing.T) {
+
+    var assertions []*jsonAssertion
+    for _, expr := range []string{"$.status == ok", "$.count > 1"} {
+        a, err := parseJSONAssertion(expr)
+        if err != nil {
+            t.Fatal(err)
+        }
+        assertions = append(assertions, a)
+    }
+
+    if p := checkJSON(assertions, []byte(`{"status": "ok", "count": 2}`)); len(p) != 0 {
+        t.Errorf("unexpected problems %q", p)
+    }
+    p := checkJSON(assertions, []byte(`{"status": "down", "count": 2}`))
+    if len(p) != 1 || !strings.HasPrefix(p[0], "$.status == ok") {
+        t.Errorf("problems = %q, want one for $.status", p)
+    }
+    p = checkJSON(assertions, []byte(`<html>`))
+    if len(p) != 1 || !strings.HasPrefix(p[0], "body is not JSON") {
+        t.Errorf("problems = %q, want the body not to be JSON", p)
+    }
+}
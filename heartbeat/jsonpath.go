@@ -0,0 +1,123 @@
+package heartbeat
+
+import (
+    "encoding/json"
+    "fmt"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+)
+
+// A jsonStep is a single step of a JSON path: an object key, an array
+// index, or the length of the value so far.
+type jsonStep struct {
+    key    string
+    index  int
+    isKey  bool
+    length bool
+}
+
+// parseJSONPath parses a simple JSONPath expression such as
+//
+//    $.quote.price
+//    $.items[0].value
+//    $['last trade'].price
+//    $.items.length()
+//
+// The leading '$' is optional. A final length() is the number of
+// elements of an array, keys of an object or characters of a string.
+func parseJSONPath(path string) ([]jsonStep, error) {
+
+    p := strings.TrimPrefix(strings.TrimSpace(path), "$")
+    var steps []jsonStep
+    for len(p) > 0 {
+        switch p[0] {
+        case '.':
+            p = p[1:]
+            end := strings.IndexAny(p, ".[")
+            if end < 0 {
+                end = len(p)
+            }
+            if end == 0 {
+                return nil, fmt.Errorf("invalid JSON path '%s': empty key", path)
+            }
+            if p[:end] == "length()" {
+                steps = append(steps, jsonStep{length: true})
+            } else {
+                steps = append(steps, jsonStep{key: p[:end], isKey: true})
+            }
+            p = p[end:]
+        case '[':
+            end := strings.IndexByte(p, ']')
+            if end < 0 {
+                return nil, fmt.Errorf("invalid JSON path '%s': missing ']'", path)
+            }
+            inner := strings.TrimSpace(p[1:end])
+            p = p[end + 1:]
+            if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner) - 1] == inner[0] {
+                steps = append(steps, jsonStep{key: inner[1:len(inner) - 1], isKey: true})
+                continue
+            }
+            n, err := strconv.Atoi(inner)
+            if err != nil {
+                return nil, fmt.Errorf("invalid JSON path '%s': bad index '%s'", path, inner)
+            }
+            steps = append(steps, jsonStep{index: n})
+        default:
+            if len(steps) > 0 {
+                return nil, fmt.Errorf("invalid JSON path '%s' at '%s'", path, p)
+            }
+            p = "." + p         // allow a bare first key, as in 'quote.price'
+        }
+    }
+    return steps, nil
+}
+
+// evalJSONPath returns the value found at the path within a decoded
+// JSON document (as produced by encoding/json).
+func evalJSONPath(doc interface{}, steps []jsonStep) (interface{}, error) {
+
+    v := doc
+    at := "$"
+    for _, s := range steps {
+        if s.length {
+            at += ".length()"
+            switch x := v.(type) {
+            case []interface{}:
+                v = json.Number(strconv.Itoa(len(x)))
+            case map[string]interface{}:
+                v = json.Number(strconv.Itoa(len(x)))
+            case string:
+                v = json.Number(strconv.Itoa(utf8.RuneCountInString(x)))
+            default:
+                return nil, fmt.Errorf("%s: not an array, object or string", at)
+            }
+            continue
+        }
+        if s.isKey {
+            obj, ok := v.(map[string]interface{})
+            if !ok {
+                return nil, fmt.Errorf("%s is not an object", at)
+            }
+            at += "." + s.key
+            if v, ok = obj[s.key]; !ok {
+                return nil, fmt.Errorf("%s not found", at)
+            }
+            continue
+        }
+        arr, ok := v.([]interface{})
+        if !ok {
+            return nil, fmt.Errorf("%s is not an array", at)
+        }
+        i := s.index
+        if i < 0 {
+            i += len(arr)       // [-1] is the last element
+        }
+        at += fmt.Sprintf("[%d]", s.index)
+        if i < 0 || i >= len(arr) {
+            return nil, fmt.Errorf("%s is out of range (length %d)", at, len(arr))
+        }
+        v = arr[i]
+    }
+    return v, nil
+}
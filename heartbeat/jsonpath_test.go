@@ -0,0 +1,121 @@
+package heartbeat
+
+import (
+    "encoding/json"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+const testJSONDoc = `{
+    "status": "ok",
+    "quote":  {"price": 12.5, "last trade": {"price": 12}},
+    "items":  [{"value": 1}, {"value": 2}, {"value": 3}],
+    "name":   "héllo",
+    "empty":  null
+}`
+
+func decodeTestJSON(t *testing.T, s string) interface{} {
+
+    dec := json.NewDecoder(strings.NewReader(s))
+    dec.UseNumber()
+    var doc interface{}
+    if err := dec.Decode(&doc); err != nil {
+        t.Fatal(err)
+    }
+    return doc
+}
+
+func TestParseJSONPath(t *testing.T) {
+
+    key   := func(k string) jsonStep { return jsonStep{key: k, isKey: true} }
+    index := func(i int) jsonStep { return jsonStep{index: i} }
+    length := jsonStep{length: true}
+
+    for _, tc := range []struct {
+        path  string
+        steps []jsonStep
+        err   string
+    }{
+        {"$",                        nil,                                      ""},
+        {"",                         nil,                                      ""},
+        {"$.quote.price",            []jsonStep{key("quote"), key("price")},   ""},
+        {"quote.price",              []jsonStep{key("quote"), key("price")},   ""},
+        {"  $.status  ",             []jsonStep{key("status")},                ""},
+        {"$.items[0].value",         []jsonStep{key("items"), index(0), key("value")}, ""},
+        {"$.items[-1]",              []jsonStep{key("items"), index(-1)},      ""},
+        {"$['last trade'].price",    []jsonStep{key("last trade"), key("price")}, ""},
+        {`$["a.b"]`,                 []jsonStep{key("a.b")},                   ""},
+        {"$[ 2 ]",                   []jsonStep{index(2)},                     ""},
+        {"$.items.length()",         []jsonStep{key("items"), length},         ""},
+        {"$..price",                 nil, "empty key"},
+        {"$.",                       nil, "empty key"},
+        {"$.items[0",                nil, "missing ']'"},
+        {"$.items[x]",               nil, "bad index 'x'"},
+        {"$.items[]",                nil, "bad index ''"},
+        {"$['unterminated]",         nil, "bad index"},
+        {"$.items[0]value",          nil, "at 'value'"},
+    } {
+        steps, err := parseJSONPath(tc.path)
+        if tc.err != "" {
+            if err == nil || !strings.Contains(err.Error(), tc.err) {
+                t.Errorf("parseJSONPath(%q) error = %v, want %q", tc.path, err, tc.err)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("parseJSONPath(%q): %v", tc.path, err)
+            continue
+        }
+        if !reflect.DeepEqual(steps, tc.steps) {
+            t.Errorf("parseJSONPath(%q) = %+v, want %+v", tc.path, steps, tc.steps)
+        }
+    }
+}
+
+func TestEvalJSONPath(t *testing.T) {
+
+    doc := decodeTestJSON(t, testJSONDoc)
+    for _, tc := range []struct {
+        path string
+        want string        // the value found, as JSON
+        err  string
+    }{
+        {"$.status",                  `"ok"`,  ""},
+        {"$.quote.price",             `12.5`,  ""},
+        {"$['last trade'].price",     "",      "$.last trade not found"},
+        {"$.quote['last trade'].price", `12`,  ""},
+        {"$.items[1].value",          `2`,     ""},
+        {"$.items[-1].value",         `3`,     ""},
+        {"$.items.length()",          `3`,     ""},
+        {"$.quote.length()",          `2`,     ""},
+        {"$.name.length()",           `5`,     ""},
+        {"$.empty",                   `null`,  ""},
+        {"$.missing",                 "",      "$.missing not found"},
+        {"$.status.code",             "",      "$.status is not an object"},
+        {"$.quote[0]",                "",      "$.quote is not an array"},
+        {"$.items[3]",                "",      "$.items[3] is out of range (length 3)"},
+        {"$.items[-4]",               "",      "$.items[-4] is out of range"},
+        {"$.quote.price.length()",    "",      "not an array, object or string"},
+    } {
+        steps, err := parseJSONPath(tc.path)
+        if err != nil {
+            t.Errorf("parseJSONPath(%q): %v", tc.path, err)
+            continue
+        }
+        v, err := evalJSONPath(doc, steps)
+        if tc.err != "" {
+            if err == nil || !strings.Contains(err.Error(), tc.err) {
+                t.Errorf("%s: error = %v, want %q", tc.path, err, tc.err)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("%s: %v", tc.path, err)
+            continue
+        }
+        if got, _ := json.Marshal(v); string(got) != tc.want {
+            t.Errorf("%s = %s, want %s", tc.path, got, tc.want)
+        }
+    }
+}
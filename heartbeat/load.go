@@ -0,0 +1,201 @@
+package heartbeat
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "sort"
+    "sync"
+    "time"
+)
+
+// LoadTest simulates a number of concurrent visitors fetching the
+// target of a Monitor, for a fixed time or number of requests.
+//
+// The Monitor's baseline is neither used nor changed.
+type LoadTest struct {
+    Monitor  *Monitor
+    Users    int               // concurrent virtual visitors
+    Duration time.Duration     // stop after this long (0 = no limit)
+    Requests int               // stop after this many requests in all (0 = no limit)
+    Rate     float64           // requests per second across all users (0 = as fast as possible)
+}
+
+// LoadReport summarizes a LoadTest.
+type LoadReport struct {
+    Requests int
+    Failures int
+    Bytes    int64
+    Elapsed  time.Duration
+    Errors   map[string]int    // count of failures by ErrorCategory or HTTP status
+    Phases   []PhaseStats
+}
+
+// PhaseStats holds the latency distribution of one phase of the
+// successful fetches.
+type PhaseStats struct {
+    Name  string
+    Count int
+    Mean  time.Duration
+    P50   time.Duration
+    P90   time.Duration
+    P99   time.Duration
+    Max   time.Duration
+}
+
+// Throughput returns the number of requests completed per second.
+func (r *LoadReport) Throughput() float64 {
+
+    if r.Elapsed <= 0 {
+        return 0
+    }
+    return float64(r.Requests) / r.Elapsed.Seconds()
+}
+
+// Run runs the load test until the duration or number of requests is
+// reached, or the context is cancelled.
+func (lt *LoadTest) Run(ctx context.Context) (*LoadReport, error) {
+
+    if lt.Monitor == nil {
+        return nil, errors.New("load test has no target")
+    }
+    if lt.Monitor.Scenario != nil {
+        return nil, errors.New("load test of a scenario is not supported")
+    }
+    if lt.Users < 1 {
+        return nil, errors.New("load test needs at least one user")
+    }
+    if lt.Duration <= 0 && lt.Requests <= 0 {
+        return nil, errors.New("load test needs a duration or a number of requests")
+    }
+    if lt.Rate < 0 {
+        return nil, errors.New("load test rate must not be negative")
+    }
+
+    if lt.Duration > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, lt.Duration)
+        defer cancel()
+    }
+
+    // Every user shares the same pool of connections, as visitors
+    // from the same proxy would.
+    config, err := lt.Monitor.TLS.clientConfig()
+    if err != nil {
+        return nil, err
+    }
+    base := newTransport(lt.Monitor.Protocol, lt.Users, config)
+    if t, ok := base.(*http.Transport); ok {
+        defer t.CloseIdleConnections()
+    }
+
+    // Requests are handed out as tickets, paced if there is a rate.
+    tickets := make(chan struct{})
+    go func() {
+        defer close(tickets)
+        var tick <-chan time.Time
+        if lt.Rate > 0 {
+            ticker := time.NewTicker(time.Duration(float64(time.Second) / lt.Rate))
+            defer ticker.Stop()
+            tick = ticker.C
+        }
+        for n := 0; lt.Requests <= 0 || n < lt.Requests; n++ {
+            if tick != nil {
+                select {
+                case <-tick:
+                case <-ctx.Done():
+                    return
+                }
+            }
+            select {
+            case tickets <- struct{}{}:
+            case <-ctx.Done():
+                return
+            }
+        }
+    }()
+
+    var mu sync.Mutex
+    report  := &LoadReport{Errors: map[string]int{}}
+    samples := map[string][]time.Duration{}
+
+    start := time.Now()
+    var wg sync.WaitGroup
+    for i := 0; i < lt.Users; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for range tickets {
+                res, _, err := lt.Monitor.fetch(ctx, base)
+                if ctx.Err() != nil && (err != nil || res.Err != nil) {
+                    return          // cut short by the end of the test
+                }
+                mu.Lock()
+                report.Requests++
+                if cause := loadFailure(res, err); cause != "" {
+                    report.Failures++
+                    report.Errors[cause]++
+                } else {
+                    report.Bytes += res.Bytes
+                    for _, p := range res.Phases() {
+                        samples[p.Name] = append(samples[p.Name], p.Duration)
+                    }
+                }
+                mu.Unlock()
+            }
+        }()
+    }
+    wg.Wait()
+    report.Elapsed = time.Since(start)
+
+    for _, name := range PhaseNames {
+        report.Phases = append(report.Phases, phaseStats(name, samples[name]))
+    }
+    return report, nil
+}
+
+// loadFailure returns the cause of a failed fetch, or "" if it succeeded.
+func loadFailure(res *Result, err error) string {
+
+    if err == nil {
+        err = res.Err
+    }
+    if err != nil {
+        return string(Classify(err))
+    }
+    if res.StatusCode >= 400 {
+        return fmt.Sprintf("HTTP %d", res.StatusCode)
+    }
+    return ""
+}
+
+func phaseStats(name string, d []time.Duration) PhaseStats {
+
+    ps := PhaseStats{Name: name, Count: len(d)}
+    if len(d) == 0 {
+        return ps
+    }
+    sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+
+    var sum time.Duration
+    for _, v := range d {
+        sum += v
+    }
+    ps.Mean = sum / time.Duration(len(d))
+    ps.P50  = percentile(d, 50)
+    ps.P90  = percentile(d, 90)
+    ps.P99  = percentile(d, 99)
+    ps.Max  = d[len(d) - 1]
+    return ps
+}
+
+// percentile returns the nearest-rank percentile of sorted durations.
+func percentile(sorted []time.Duration, p int) time.Duration {
+
+    rank := (p * len(sorted) + 99) / 100
+    if rank < 1 {
+        rank = 1
+    }
+    return sorted[rank - 1]
+}
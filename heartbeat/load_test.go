@@ -0,0 +1,95 @@
+package heartbeat
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func TestPercentile(t *testing.T) {
+
+    var d []time.Duration
+    for i := 1; i <= 10; i++ {
+        d = append(d, time.Duration(i) * time.Millisecond)
+    }
+    for _, tc := range []struct {
+        sorted []time.Duration
+        p      int
+        want   time.Duration
+    }{
+        {d,         50,  5 * time.Millisecond},
+        {d,         90,  9 * time.Millisecond},
+        {d,         99,  10 * time.Millisecond},
+        {d,         1,   1 * time.Millisecond},
+        {d,         0,   1 * time.Millisecond},
+        {d[:1],     99,  1 * time.Millisecond},
+        {d[:3],     50,  2 * time.Millisecond},
+    } {
+        if got := percentile(tc.sorted, tc.p); got != tc.want {
+            t.Errorf("p%d of %v = %v, want %v", tc.p, tc.sorted, got, tc.want)
+        }
+    }
+}
+
+func TestPhaseStats(t *testing.T) {
+
+    ms := time.Millisecond
+    ps := phaseStats("total", []time.Duration{40 * ms, 10 * ms, 30 * ms, 20 * ms})
+    want := PhaseStats{Name: "total", Count: 4, Mean: 25 * ms, P50: 20 * ms, P90: 40 * ms, P99: 40 * ms, Max: 40 * ms}
+    if ps != want {
+        t.Errorf("got %+v, want %+v", ps, want)
+    }
+    if ps := phaseStats("dns", nil); ps != (PhaseStats{Name: "dns"}) {
+        t.Errorf("no samples gave %+v", ps)
+    }
+}
+
+func TestLoadTestRun(t *testing.T) {
+
+    var n int32
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+        if atomic.AddInt32(&n, 1) % 4 == 0 {
+            http.Error(w, "busy", http.StatusServiceUnavailable)
+            return
+        }
+        w.Write([]byte("0123456789"))
+    }))
+    defer srv.Close()
+
+    lt := &LoadTest{Monitor: NewMonitor(srv.URL), Users: 3, Requests: 20}
+    r, err := lt.Run(context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if r.Requests != 20 || r.Failures != 5 || r.Errors["HTTP 503"] != 5 || r.Bytes != 150 {
+        t.Errorf("report %+v", r)
+    }
+    for _, ps := range r.Phases {
+        if ps.Name == "total" && (ps.Count != 15 || ps.P50 > ps.P90 || ps.P90 > ps.Max) {
+            t.Errorf("total %+v", ps)
+        }
+    }
+}
+
+func TestLoadTestInvalid(t *testing.T) {
+
+    m := NewMonitor("http://localhost/")
+    for _, tc := range []struct {
+        lt  LoadTest
+        err string
+    }{
+        {LoadTest{Users: 1, Requests: 1},                     "no target"},
+        {LoadTest{Monitor: m, Requests: 1},                   "at least one user"},
+        {LoadTest{Monitor: m, Users: 1},                      "a duration or a number of requests"},
+        {LoadTest{Monitor: m, Users: 1, Requests: 1, Rate: -1}, "must not be negative"},
+    } {
+        if _, err := tc.lt.Run(context.Background()); err == nil || !strings.Contains(err.Error(), tc.err) {
+            t.Errorf("%+v: error %v, want %q", tc.lt, err, tc.err)
+        }
+    }
+}
@@ -0,0 +1,249 @@
+package heartbeat
+
+import (
+    "bytes"
+    "fmt"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+// DefaultBuckets are the upper bounds (in seconds) of the phase latency
+// histograms, as used by the Prometheus client libraries.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics collects the results of checks and serves them, as an
+// http.Handler, in the Prometheus text exposition format. Every metric
+// is labelled with the target's name and URL.
+//
+// A Metrics may be shared by any number of Monitors.
+type Metrics struct {
+    Buckets []float64           // defaults to DefaultBuckets
+
+    mu      sync.Mutex
+    targets map[string]*targetMetrics
+}
+
+// targetMetrics holds the metrics of one target.
+type targetMetrics struct {
+    labels   string              // target="...",url="..."
+    last     *Result
+    baseline Baseline
+    checks   int64
+    errors   map[ErrorCategory]int64
+    alerts   map[AlertKind]int64
+    phases   map[string]*histogram
+}
+
+type histogram struct {
+    counts []int64              // per bucket, not cumulative
+    count  int64
+    sum    float64
+}
+
+// NewMetrics returns an empty Metrics.
+func NewMetrics() *Metrics {
+
+    return &Metrics{Buckets: DefaultBuckets}
+}
+
+// Observe records the result of a check of the Monitor's target.
+func (x *Metrics) Observe(m *Monitor, res *Result) {
+
+    baseline := m.Baseline()
+
+    x.mu.Lock()
+    defer x.mu.Unlock()
+
+    if x.targets == nil {
+        x.targets = map[string]*targetMetrics{}
+    }
+    t := x.targets[m.Name]
+    if t == nil {
+        t = &targetMetrics{
+            labels: fmt.Sprintf("target=%s,url=%s", quote(m.Name), quote(m.URL)),
+            errors: map[ErrorCategory]int64{},
+            alerts: map[AlertKind]int64{},
+            phases: map[string]*histogram{},
+        }
+        x.targets[m.Name] = t
+    }
+    t.last, t.baseline = res, baseline
+    t.checks++
+    if res.Err != nil {
+        t.errors[res.Category]++
+    }
+    for _, a := range res.Alerts {
+        t.alerts[a.Kind]++
+    }
+    if res.Err == nil {
+        for _, p := range res.Phases() {
+            h := t.phases[p.Name]
+            if h == nil {
+                h = &histogram{counts: make([]int64, len(x.buckets()) + 1)}
+                t.phases[p.Name] = h
+            }
+            secs := p.Duration.Seconds()
+            i := sort.SearchFloat64s(x.buckets(), secs)
+            h.counts[i]++
+            h.count++
+            h.sum += secs
+        }
+    }
+}
+
+func (x *Metrics) buckets() []float64 {
+
+    if len(x.Buckets) == 0 {
+        return DefaultBuckets
+    }
+    return x.Buckets
+}
+
+// ServeHTTP writes the metrics.
+func (x *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+    w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+    w.Write(x.exposition())
+}
+
+// exposition returns the metrics in the Prometheus text format.
+func (x *Metrics) exposition() []byte {
+
+    x.mu.Lock()
+    defer x.mu.Unlock()
+
+    var names []string
+    for name := range x.targets {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    targets := make([]*targetMetrics, len(names))
+    for i, name := range names {
+        targets[i] = x.targets[name]
+    }
+
+    var b bytes.Buffer
+    family := func(name, kind, help string, each func(t *targetMetrics)) {
+        fmt.Fprintf(&b, "# HELP heartbeat_%s %s\n", name, help)
+        fmt.Fprintf(&b, "# TYPE heartbeat_%s %s\n", name, kind)
+        for _, t := range targets {
+            each(t)
+        }
+    }
+    sample := func(name, labels string, v float64) {
+        fmt.Fprintf(&b, "heartbeat_%s{%s} %s\n", name, labels, strconv.FormatFloat(v, 'g', -1, 64))
+    }
+    flag := func(ok bool) float64 {
+        if ok {
+            return 1
+        }
+        return 0
+    }
+
+    family("up", "gauge", "Whether the last fetch of the target succeeded.", func(t *targetMetrics) {
+        sample("up", t.labels, flag(t.last.Err == nil))
+    })
+    family("state", "gauge", "State of the target: 0 OK, 1 WARNING, 2 CRITICAL, 3 UNKNOWN.", func(t *targetMetrics) {
+        sample("state", t.labels, float64(t.last.State))
+    })
+    family("flapping", "gauge", "Whether the target is flapping.", func(t *targetMetrics) {
+        sample("flapping", t.labels, flag(t.last.Flapping))
+    })
+    family("consecutive_failures", "gauge", "Number of failed checks in a row.", func(t *targetMetrics) {
+        sample("consecutive_failures", t.labels, float64(t.last.Failing))
+    })
+    family("status_code", "gauge", "HTTP status code of the last response (0 if none).", func(t *targetMetrics) {
+        sample("status_code", t.labels, float64(t.last.StatusCode))
+    })
+    family("response_bytes", "gauge", "Size of the last response body.", func(t *targetMetrics) {
+        sample("response_bytes", t.labels, float64(t.last.Bytes))
+    })
+    family("last_check_timestamp_seconds", "gauge", "When the last check started.", func(t *targetMetrics) {
+        sample("last_check_timestamp_seconds", t.labels, float64(t.last.Start.UnixNano()) / 1e9)
+    })
+    family("value", "gauge", "Value extracted from the last response, if any.", func(t *targetMetrics) {
+        if t.last.Value != nil {
+            sample("value", t.labels, *t.last.Value)
+        }
+    })
+    family("step_duration_seconds", "gauge", "Round trip time of each step of the last scenario run, if any.", func(t *targetMetrics) {
+        for _, step := range t.last.Steps {
+            sample("step_duration_seconds", t.labels + ",step=" + quote(step.Name), step.Trip.Seconds())
+        }
+    })
+    family("certificate_expiry_timestamp_seconds", "gauge", "When the first certificate in the chain expires (HTTPS only).", func(t *targetMetrics) {
+        if t.last.TLSInfo != nil && !t.last.TLSInfo.Expires().IsZero() {
+            sample("certificate_expiry_timestamp_seconds", t.labels, float64(t.last.TLSInfo.Expires().Unix()))
+        }
+    })
+    family("baseline_time_seconds", "gauge", "Response time baseline (centre, lower and upper limits).", func(t *targetMetrics) {
+        ms := func(v int64) float64 { return float64(v) / 1000 }
+        sample("baseline_time_seconds", t.labels + `,bound="centre"`, ms(t.baseline.Time))
+        sample("baseline_time_seconds", t.labels + `,bound="lower"`,  ms(t.baseline.TimeLo))
+        sample("baseline_time_seconds", t.labels + `,bound="upper"`,  ms(t.baseline.TimeHi))
+    })
+    family("baseline_bytes", "gauge", "Response size baseline (centre, lower and upper limits).", func(t *targetMetrics) {
+        sample("baseline_bytes", t.labels + `,bound="centre"`, float64(t.baseline.Bytes))
+        sample("baseline_bytes", t.labels + `,bound="lower"`,  float64(t.baseline.BytesLo))
+        sample("baseline_bytes", t.labels + `,bound="upper"`,  float64(t.baseline.BytesHi))
+    })
+    family("checks_total", "counter", "Number of checks.", func(t *targetMetrics) {
+        sample("checks_total", t.labels, float64(t.checks))
+    })
+    family("errors_total", "counter", "Number of failed fetches, by category.", func(t *targetMetrics) {
+        for _, c := range sortedKeys(t.errors) {
+            sample("errors_total", t.labels + ",category=" + quote(c), float64(t.errors[ErrorCategory(c)]))
+        }
+    })
+    family("alerts_total", "counter", "Number of alerts raised (not counting those suppressed), by kind.", func(t *targetMetrics) {
+        for _, k := range sortedKeys(t.alerts) {
+            sample("alerts_total", t.labels + ",kind=" + quote(k), float64(t.alerts[AlertKind(k)]))
+        }
+    })
+    family("phase_seconds", "histogram", "Duration of each phase of successful fetches.", func(t *targetMetrics) {
+        for _, name := range PhaseNames {
+            h := t.phases[name]
+            if h == nil {
+                continue
+            }
+            labels := t.labels + ",phase=" + quote(name)
+            var n int64
+            for i, le := range x.buckets() {
+                n += h.counts[i]
+                fmt.Fprintf(&b, "heartbeat_phase_seconds_bucket{%s,le=\"%s\"} %d\n", labels, strconv.FormatFloat(le, 'g', -1, 64), n)
+            }
+            fmt.Fprintf(&b, "heartbeat_phase_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+            fmt.Fprintf(&b, "heartbeat_phase_seconds_sum{%s} %s\n", labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+            fmt.Fprintf(&b, "heartbeat_phase_seconds_count{%s} %d\n", labels, h.count)
+        }
+    })
+    return b.Bytes()
+}
+
+// quote returns a label value, quoted and escaped.
+func quote(s string) string {
+
+    s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+    return `"` + s + `"`
+}
+
+// sortedKeys returns the keys of a map of counts, sorted.
+func sortedKeys(counts interface{}) []string {
+
+    var keys []string
+    switch c := counts.(type) {
+    case map[ErrorCategory]int64:
+        for k := range c {
+            keys = append(keys, string(k))
+        }
+    case map[AlertKind]int64:
+        for k := range c {
+            keys = append(keys, string(k))
+        }
+    }
+    sort.Strings(keys)
+    return keys
+}
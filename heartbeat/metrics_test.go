@@ -0,0 +1,68 @@
+package heartbeat
+
+import (
+    "errors"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestMetrics(t *testing.T) {
+
+    m := NewMonitor("http://localhost/")
+    m.Name = "shop \"main\"\\eu\nwest"
+    x := &Metrics{Buckets: []float64{.01, .1, 1}}
+    for _, trip := range []time.Duration{5 * time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond, 2 * time.Second} {
+        x.Observe(m, &Result{Name: m.Name, URL: m.URL, StatusCode: 200, Trip: trip})
+    }
+    failed := &Result{Name: m.Name, URL: m.URL, Err: errors.New("refused"), Category: ErrorRefused}
+    failed.alert(AlertError, "request failed")
+    x.Observe(m, failed)
+
+    w := httptest.NewRecorder()
+    x.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+    if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+        t.Errorf("content type %q", ct)
+    }
+    exposition := w.Body.String()
+
+    labels := `target="shop \"main\"\\eu\nwest",url="http://localhost/"`
+    for _, want := range []string{
+        `heartbeat_up{` + labels + `} 0`,
+        `heartbeat_checks_total{` + labels + `} 5`,
+        `heartbeat_errors_total{` + labels + `,category="refused"} 1`,
+        `heartbeat_alerts_total{` + labels + `,kind="error"} 1`,
+        // Cumulative, counting only the successful fetches.
+        `heartbeat_phase_seconds_bucket{` + labels + `,phase="total",le="0.01"} 2`,
+        `heartbeat_phase_seconds_bucket{` + labels + `,phase="total",le="0.1"} 3`,
+        `heartbeat_phase_seconds_bucket{` + labels + `,phase="total",le="1"} 3`,
+        `heartbeat_phase_seconds_bucket{` + labels + `,phase="total",le="+Inf"} 4`,
+        `heartbeat_phase_seconds_sum{` + labels + `,phase="total"} 2.065`,
+        `heartbeat_phase_seconds_count{` + labels + `,phase="total"} 4`,
+        "# TYPE heartbeat_phase_seconds histogram",
+    } {
+        if !strings.Contains(exposition, want + "\n") {
+            t.Errorf("missing %s", want)
+        }
+    }
+    if t.Failed() {
+        t.Log(exposition)
+    }
+}
+
+func TestQuote(t *testing.T) {
+
+    for _, tc := range []struct {
+        s, want string
+    }{
+        {`plain`,       `"plain"`},
+        {`say "hi"`,    `"say \"hi\""`},
+        {`C:\temp`,     `"C:\\temp"`},
+        {"two\nlines",  `"two\nlines"`},
+    } {
+        if got := quote(tc.s); got != tc.want {
+            t.Errorf("quote(%q) = %s, want %s", tc.s, got, tc.want)
+        }
+    }
+}
@@ -0,0 +1,330 @@
+// Package heartbeat polls a web target at a regular interval and
+// reports on how the target is responding.
+//
+// When the first fetch completes, the returned length will be saved,
+// and lengths that differ from this by more or less than the specified
+// variance on later fetches will generate alerts.
+//
+// In a similiar manner, significant deviations from the initial fetch
+// time will also generate an alert.
+//
+// Alternatively the size and time baselines may be statistical - a
+// rolling mean, moving average or rolling median of recent fetches -
+// or fixed limits (see BaselineConfig).
+//
+// Fetches that do not complete within the timeout period will generate
+// another type of alert.
+//
+// The first DNS lookup is ignored for variance purposes. This is because
+// the very first lookup may well take a significant amount of time
+// compared to subsequent lookups when this value will be served from cache.
+//
+// Each Monitor carries its own baseline, so any number of targets may
+// be checked from within the same process.
+//
+// REQUIRES Go 1.24 (for http.Protocols; see go.mod)
+package heartbeat
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "net/url"
+    "strings"
+    "sync"
+    "time"
+)
+
+const (
+    DefaultURL      = "http://localhost"
+    DefaultInterval =  5 * time.Minute     // every 5 minutes
+    DefaultTimeout  = 10 * time.Second     // 10 seconds
+    DefaultVariance =  5                   // 5 percent (%)
+)
+
+// Monitor polls a single target and keeps track of its baseline.
+//
+// The exported fields should be set before the first call to Run or
+// CheckOnce and not changed afterwards.
+type Monitor struct {
+    Name     string            // identifies the target in results, defaults to URL
+    URL      string
+    Interval time.Duration     // time to sleep between fetches
+    Timeout  time.Duration     // fetches taking longer than this will fail
+    Variance int               // allowable variance (percent) in size or time
+    Header   http.Header       // additional request headers
+    Protocol Protocol          // HTTP version to use (and expect), if any
+
+    // Method (GET by default), Query, Body, Auth and UserAgent complete
+    // the request. Query is added to the URL's query string and the
+    // UserAgent overrides any User-Agent header.
+    Method    string
+    Query     url.Values
+    Body      []byte            // sent with each request, if not nil
+    Auth      *Auth             // credentials, if any
+    UserAgent string
+
+    // TimeBaseline and SizeBaseline select how the response time and
+    // size baselines are established (by default, using Variance).
+    TimeBaseline BaselineConfig
+    SizeBaseline BaselineConfig
+
+    // Policy decides when problems become alerts (and recoveries).
+    Policy   AlertPolicy
+
+    // Redirects decides how redirects are followed and checked.
+    Redirects RedirectPolicy
+
+    // TLS decides what is expected of HTTPS connections.
+    TLS      TLSPolicy
+
+    // PhaseWarning and PhaseCritical limit the individual phases of
+    // each fetch, by the names in PhaseNames (as in "tls" or "wait"):
+    // a phase taking longer raises an alert, and makes the target's
+    // state WARNING or CRITICAL.
+    PhaseWarning  map[string]time.Duration
+    PhaseCritical map[string]time.Duration
+
+    // Value, if not nil, extracts a number from the response body and
+    // alerts on thresholds or significant changes (Stop-Loss).
+    Value    *ValueCheck
+
+    // Assert, if not nil, checks the status code, headers and body of
+    // each response.
+    Assert   *Assertions
+
+    // Scenario, if not nil, is run as each check instead of a single
+    // fetch of the URL (which then just identifies the target).
+    Scenario *Scenario
+
+    // Verbose, if not nil, receives diagnostic messages about each fetch.
+    Verbose  io.Writer
+
+    // OnResult, if not nil, is called by Run with the result of each fetch.
+    OnResult func(*Result)
+
+    // Notifiers are sent every alert and notice raised by Run.
+    Notifiers []Notifier
+
+    // Store, if not nil, keeps the result of every check made by Run.
+    Store    *Store
+
+    // Metrics and Dashboard, if not nil, record the result of every
+    // check made by Run.
+    Metrics   *Metrics
+    Dashboard *Dashboard
+
+    // Events, if not nil, receives an Event as each check made by Run
+    // starts and completes, and for every alert, notice and error.
+    Events   *EventLog
+
+    // ErrorLog, if not nil, receives errors that cannot be reported
+    // any other way (such as failed notifications). If nil, errors are
+    // logged with the log package's standard logger. They are also
+    // sent to Events, if any.
+    ErrorLog *log.Logger
+
+    mu          sync.Mutex
+    baseline    Baseline
+    value       *float64          // found by the previous fetch
+    times       series            // response times, for the statistical baselines
+    sizes       series
+    chain       *[]string         // redirects followed by the previous fetch
+    tlsVersion  uint16            // negotiated by the previous fetch
+    stepTLS     []uint16          // negotiated by each step of the previous Scenario check
+    status      targetState
+    rtOnce      sync.Once
+    rt          http.RoundTripper // pinned to the Protocol and TLSPolicy, if any
+    rtErr       error
+    limit       chan struct{}     // shared with other Monitors in the same Pool
+    jar         http.CookieJar    // of the Scenario being run, if any
+    keepBody    bool              // a Scenario step captures from the body
+}
+
+// Baseline holds the values that later fetches are compared against.
+//
+// Sizes are in bytes and times are in milliseconds. A zero Trip or
+// Bytes value means that no baseline has been established yet. With
+// a statistical strategy, Trip (and Time) and Bytes are the centre of
+// the baseline.
+type Baseline struct {
+    Bytes   uint64
+    BytesLo uint64
+    BytesHi uint64
+    Trip    int64      // round trip time
+    Time    int64      // round trip time ignoring the first DNS lookup
+    TimeLo  int64
+    TimeHi  int64
+}
+
+// NewMonitor returns a Monitor for the specified URL with the default
+// polling interval, timeout and variance.
+func NewMonitor(url string) *Monitor {
+
+    return &Monitor{
+        Name:     url,
+        URL:      url,
+        Interval: DefaultInterval,
+        Timeout:  DefaultTimeout,
+        Variance: DefaultVariance,
+    }
+}
+
+// Validate checks that the Monitor's settings are usable, so that problems
+// are found before polling starts rather than at the first fetch.
+func (m *Monitor) Validate() error {
+
+    u, err := url.Parse(m.URL)
+    if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+        return fmt.Errorf("invalid URL '%s'", m.URL)
+    }
+    if m.Interval <= 0 || m.Timeout <= 0 {
+        return fmt.Errorf("interval and timeout must be greater than zero")
+    }
+    if m.Variance < 0 || m.Variance > 100 {
+        return fmt.Errorf("variance must be between 0 and 100 percent")
+    }
+    if err := m.Protocol.checkURL(u.Scheme); err != nil {
+        return err
+    }
+    if err := m.validateRequest(); err != nil {
+        return err
+    }
+    if err := m.TimeBaseline.Validate(); err != nil {
+        return fmt.Errorf("time baseline: %v", err)
+    }
+    if err := m.SizeBaseline.Validate(); err != nil {
+        return fmt.Errorf("size baseline: %v", err)
+    }
+    if err := m.Policy.Validate(); err != nil {
+        return err
+    }
+    if err := m.Redirects.Validate(); err != nil {
+        return err
+    }
+    if err := m.TLS.Validate(); err != nil {
+        return err
+    }
+    for _, limits := range []map[string]time.Duration{m.PhaseWarning, m.PhaseCritical} {
+        for name, d := range limits {
+            if !knownPhase(name) {
+                return fmt.Errorf("unknown phase '%s' (use %s)", name, strings.Join(PhaseNames, ", "))
+            }
+            if d <= 0 {
+                return fmt.Errorf("%s limit must be greater than zero", name)
+            }
+        }
+    }
+    if m.Value != nil {
+        if err := m.Value.Validate(); err != nil {
+            return err
+        }
+    }
+    if m.Assert != nil {
+        if err := m.Assert.Validate(); err != nil {
+            return err
+        }
+    }
+    if m.Scenario != nil {
+        if err := m.Scenario.Validate(); err != nil {
+            return err
+        }
+        if name := m.scenarioIgnores(); name != "" {
+            return fmt.Errorf("%s is not used by a scenario", name)
+        }
+    }
+    return nil
+}
+
+// Baseline returns a copy of the current baseline.
+func (m *Monitor) Baseline() Baseline {
+
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.baseline
+}
+
+// Run fetches the target, reports the result, then sleeps for the
+// polling interval - until the context is cancelled or a check cannot
+// be carried out at all (for example, the request is invalid). Failed
+// fetches, including those to unreachable hosts, are reported as
+// alerts (subject to the AlertPolicy) and polling continues.
+func (m *Monitor) Run(ctx context.Context) error {
+
+    for {
+        if m.limit != nil {
+            select {
+            case m.limit <- struct{}{}:
+            case <-ctx.Done():
+                return ctx.Err()
+            }
+        }
+        m.event(Event{Event: EventCheckStarted})
+        res, err := m.CheckOnce(ctx)
+        if m.limit != nil {
+            <-m.limit
+        }
+        if ctx.Err() != nil {
+            return ctx.Err()
+        }
+        if err != nil {
+            return err
+        }
+        m.track(res)
+        if m.OnResult != nil {
+            m.OnResult(res)
+        }
+        m.logEvents(res)
+        if m.Metrics != nil {
+            m.Metrics.Observe(m, res)
+        }
+        if m.Dashboard != nil {
+            m.Dashboard.Observe(m, res)
+        }
+        if m.Store != nil {
+            if err := m.Store.Append(res); err != nil {
+                m.logf("%s: unable to store result: %v", m.Name, err)
+            }
+        }
+        m.notify(ctx, res)
+
+        select {
+        case <-ctx.Done():
+            return ctx.Err()
+        case <-time.After(m.Interval):
+        }
+    }
+}
+
+// roundTripper returns the transport to fetch with, or nil for the default.
+func (m *Monitor) roundTripper() (http.RoundTripper, error) {
+
+    m.rtOnce.Do(func() {
+        config, err := m.TLS.clientConfig()
+        if err != nil {
+            m.rtErr = err
+        } else if m.Protocol != ProtocolAny || config != nil {
+            m.rt = newTransport(m.Protocol, 0, config)
+        }
+    })
+    return m.rt, m.rtErr
+}
+
+func (m *Monitor) logf(format string, args ...interface{}) {
+
+    m.event(Event{Event: EventError, Error: fmt.Sprintf(format, args...)})
+    if m.ErrorLog != nil {
+        m.ErrorLog.Printf("heartbeat: " + format, args...)
+    } else {
+        log.Printf("heartbeat: " + format, args...)
+    }
+}
+
+func (m *Monitor) debugf(format string, args ...interface{}) {
+
+    if m.Verbose != nil {
+        fmt.Fprintf(m.Verbose, format, args...)
+    }
+}
@@ -0,0 +1,154 @@
+package heartbeat
+
+import (
+    "fmt"
+    "strings"
+    "time"
+)
+
+// Status is the health of a target. The values are the return codes
+// used by Nagios (and Icinga) plugins.
+type Status int
+
+const (
+    StatusOK       Status = iota
+    StatusWarning
+    StatusCritical
+    StatusUnknown
+)
+
+func (s Status) String() string {
+
+    switch s {
+    case StatusOK:
+        return "OK"
+    case StatusWarning:
+        return "WARNING"
+    case StatusCritical:
+        return "CRITICAL"
+    }
+    return "UNKNOWN"
+}
+
+// Thresholds are absolute limits on a single fetch, as opposed to the
+// variance from the baseline. Zero values are not checked.
+type Thresholds struct {
+    Warning  time.Duration     // round trip times above this are WARNING
+    Critical time.Duration     // round trip times above this are CRITICAL
+    MinBytes int64             // response sizes below this are WARNING
+    MaxBytes int64             // response sizes above this are WARNING
+
+    // PhaseWarning and PhaseCritical limit the individual phases of a
+    // fetch, by the names used by Result.Phases (as in "tls" or "wait").
+    PhaseWarning  map[string]time.Duration
+    PhaseCritical map[string]time.Duration
+}
+
+// Evaluate returns the status of a fetch along with a brief description.
+// The error is the one returned by CheckOnce, if any: the check could
+// not be carried out, so the status is UNKNOWN.
+func (th Thresholds) Evaluate(res *Result, err error) (Status, string) {
+
+    if err != nil {
+        return StatusUnknown, fmt.Sprintf("%s: %v", Classify(err), err)
+    }
+    if res.Err != nil {
+        return StatusCritical, fmt.Sprintf("%s: %v", res.Category, res.Err)
+    }
+
+    secs := float64(res.Trip) / float64(time.Second)
+    summary := fmt.Sprintf("HTTP/%d.%d %s - %d bytes in %.3f second response time",
+                           res.ProtoMajor, res.ProtoMinor, res.Status, res.Bytes, secs)
+    switch n := len(res.Steps); {
+    case n == 1:
+        summary += ", 1 step"
+    case n > 1:
+        summary += fmt.Sprintf(", %d steps", n)
+    }
+
+    for _, limits := range []struct {
+        status Status
+        phases map[string]time.Duration
+        name   string
+    }{
+        {StatusCritical, th.PhaseCritical, "critical"},
+        {StatusWarning,  th.PhaseWarning,  "warning"},
+    } {
+        for _, p := range res.Phases() {
+            if limit, ok := limits.phases[p.Name]; ok && p.Duration > limit {
+                return limits.status, fmt.Sprintf("%s (%s %v, %s above %v)", summary, p.Name,
+                                                  p.Duration.Round(time.Microsecond), limits.name, limit)
+            }
+        }
+    }
+
+    switch {
+    case th.Critical > 0 && res.Trip > th.Critical:
+        return StatusCritical, fmt.Sprintf("%s (critical above %v)", summary, th.Critical)
+    case th.Warning > 0 && res.Trip > th.Warning:
+        return StatusWarning, fmt.Sprintf("%s (warning above %v)", summary, th.Warning)
+    case th.MinBytes > 0 && res.Bytes < th.MinBytes:
+        return StatusWarning, fmt.Sprintf("%s (expected at least %d bytes)", summary, th.MinBytes)
+    case th.MaxBytes > 0 && res.Bytes > th.MaxBytes:
+        return StatusWarning, fmt.Sprintf("%s (expected at most %d bytes)", summary, th.MaxBytes)
+    }
+    if res.Value != nil {
+        summary += fmt.Sprintf(", value %v", *res.Value)
+    }
+    for _, a := range res.Alerts {
+        if a.Kind == AlertValue || a.Kind == AlertAssertion {
+            return StatusCritical, fmt.Sprintf("%s (%s)", summary, a.Message)
+        }
+    }
+    if len(res.Alerts) > 0 {
+        return StatusWarning, fmt.Sprintf("%s (%s)", summary, res.Alerts[0].Message)
+    }
+    return StatusOK, summary
+}
+
+// Perfdata returns the Nagios performance data for a fetch, including
+// the round trip time of each step of a scenario. If label is not empty
+// it prefixes each value's name, as in 'label_total'. Names are quoted,
+// with any quotes within them doubled, as the plugin guidelines require.
+func (th Thresholds) Perfdata(label string, res *Result) string {
+
+    if label != "" {
+        label = perfName(label) + "_"
+    }
+    ms := func(d time.Duration) string {
+        return fmt.Sprintf("%.3f", float64(d) / float64(time.Millisecond))
+    }
+    limit := func(d time.Duration) string {
+        if d <= 0 {
+            return ""                   // no threshold
+        }
+        return ms(d)
+    }
+    var perf []string
+    for _, p := range res.Phases() {
+        warn, crit := th.PhaseWarning[p.Name], th.PhaseCritical[p.Name]
+        if p.Name == "total" {
+            if warn == 0 {
+                warn = th.Warning
+            }
+            if crit == 0 {
+                crit = th.Critical
+            }
+        }
+        perf = append(perf, fmt.Sprintf("'%s%s'=%sms;%s;%s;0", label, p.Name, ms(p.Duration), limit(warn), limit(crit)))
+    }
+    perf = append(perf, fmt.Sprintf("'%sbytes'=%dB;;;0", label, res.Bytes))
+    for _, step := range res.Steps {
+        perf = append(perf, fmt.Sprintf("'%s%s'=%sms;;;0", label, perfName(step.Name), ms(step.Trip)))
+    }
+    if res.Value != nil {
+        perf = append(perf, fmt.Sprintf("'%svalue'=%v", label, *res.Value))
+    }
+    return strings.Join(perf, " ")
+}
+
+// perfName escapes the quotes within the name of a performance value.
+func perfName(s string) string {
+
+    return strings.Replace(s, "'", "''", -1)
+}
@@ -0,0 +1,78 @@
+package heartbeat
+
+import (
+    "context"
+    "errors"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestEvaluate(t *testing.T) {
+
+    ok := func() *Result {
+        return &Result{Status: "200 OK", StatusCode: 200, ProtoMajor: 1, ProtoMinor: 1,
+                       Bytes: 1000, Trip: 300 * time.Millisecond, TLS: 50 * time.Millisecond}
+    }
+    slow := ok()
+    slow.alert(AlertTime, "previously 100 ms, now 300 ms")
+    wrong := ok()
+    wrong.alert(AlertAssertion, "status 500, expected 200")
+    failed := &Result{Err: errors.New("connection refused"), Category: ErrorRefused}
+
+    for _, tc := range []struct {
+        name   string
+        th     Thresholds
+        res    *Result
+        err    error
+        status Status
+        desc   string
+    }{
+        {"check not carried out", Thresholds{}, nil, context.Canceled, StatusUnknown, "context canceled"},
+        {"fetch failed",          Thresholds{}, failed, nil, StatusCritical, "refused: connection refused"},
+        {"ok",                    Thresholds{}, ok(), nil, StatusOK, "HTTP/1.1 200 OK - 1000 bytes in 0.300 second response time"},
+        {"time warning",          Thresholds{Warning: 200 * time.Millisecond}, ok(), nil, StatusWarning, "(warning above 200ms)"},
+        {"time critical",         Thresholds{Warning: 100 * time.Millisecond, Critical: 200 * time.Millisecond}, ok(), nil,
+                                  StatusCritical, "(critical above 200ms)"},
+        {"too small",             Thresholds{MinBytes: 2000}, ok(), nil, StatusWarning, "(expected at least 2000 bytes)"},
+        {"too big",               Thresholds{MaxBytes: 500}, ok(), nil, StatusWarning, "(expected at most 500 bytes)"},
+        {"phase warning",         Thresholds{PhaseWarning: map[string]time.Duration{"tls": 20 * time.Millisecond}}, ok(), nil,
+                                  StatusWarning, "(tls 50ms, warning above 20ms)"},
+        {"phase critical",        Thresholds{PhaseWarning:  map[string]time.Duration{"tls": 20 * time.Millisecond},
+                                             PhaseCritical: map[string]time.Duration{"total": 250 * time.Millisecond}}, ok(), nil,
+                                  StatusCritical, "(total 300ms, critical above 250ms)"},
+        {"alert",                 Thresholds{}, slow, nil, StatusWarning, "(previously 100 ms, now 300 ms)"},
+        {"assertion",             Thresholds{}, wrong, nil, StatusCritical, "(status 500, expected 200)"},
+    } {
+        status, desc := tc.th.Evaluate(tc.res, tc.err)
+        if status != tc.status || !strings.Contains(desc, tc.desc) {
+            t.Errorf("%s: %s %q, want %s %q", tc.name, status, desc, tc.status, tc.desc)
+        }
+    }
+}
+
+func TestPerfdata(t *testing.T) {
+
+    res := &Result{Bytes: 1000, Trip: 300 * time.Millisecond, Connect: 10 * time.Millisecond}
+    res.Steps = []*Result{{Name: "log in", Trip: 100 * time.Millisecond}, {Name: "user's page", Trip: 200 * time.Millisecond}}
+    value := 12.5
+    res.Value = &value
+    th := Thresholds{Warning: 200 * time.Millisecond, PhaseCritical: map[string]time.Duration{"connect": time.Second}}
+
+    perf := th.Perfdata("shop's", res)
+    for _, want := range []string{
+        "'shop''s_connect'=10.000ms;;1000.000;0",
+        "'shop''s_total'=300.000ms;200.000;;0",
+        "'shop''s_bytes'=1000B;;;0",
+        "'shop''s_log in'=100.000ms;;;0",
+        "'shop''s_user''s page'=200.000ms;;;0",
+        "'shop''s_value'=12.5",
+    } {
+        if !strings.Contains(perf, want) {
+            t.Errorf("perfdata %q does not contain %q", perf, want)
+        }
+    }
+    if perf := th.Perfdata("", &Result{Bytes: 10}); !strings.Contains(perf, " 'bytes'=10B;;;0") {
+        t.Errorf("perfdata without a label %q", perf)
+    }
+}
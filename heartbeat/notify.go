@@ -0,0 +1,210 @@
+package heartbeat
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net"
+    "net/http"
+    "net/smtp"
+    "os"
+    "os/exec"
+    "strings"
+    "time"
+)
+
+// DefaultNotifyTimeout limits how long a notifier may take.
+const DefaultNotifyTimeout = 10 * time.Second
+
+// Notification describes an alert (or a notice, such as a recovery)
+// raised for a target.
+type Notification struct {
+    Target   string        `json:"target"`
+    URL      string        `json:"url"`
+    Kind     AlertKind     `json:"kind"`
+    Category ErrorCategory `json:"category,omitempty"`
+    Time     time.Time     `json:"time"`
+    Message  string        `json:"message"`
+    Notice   bool          `json:"notice"`    // informational rather than a problem
+    State    string        `json:"state"`     // of the target: OK, WARNING, CRITICAL or UNKNOWN
+}
+
+func newNotification(res *Result, a Alert, notice bool) Notification {
+
+    return Notification{
+        Target:   res.Name,
+        URL:      res.URL,
+        Kind:     a.Kind,
+        Category: a.Category,
+        Time:     a.Time,
+        Message:  a.Message,
+        Notice:   notice,
+        State:    res.State.String(),
+    }
+}
+
+// Notifier delivers notifications somewhere that someone will see them.
+type Notifier interface {
+    Notify(ctx context.Context, n Notification) error
+}
+
+// notify sends each alert and notice of a result to every notifier.
+// Failures are logged, as there is nobody else to tell.
+func (m *Monitor) notify(ctx context.Context, res *Result) {
+
+    if len(m.Notifiers) == 0 {
+        return
+    }
+    var all []Notification
+    for _, a := range res.Alerts {
+        all = append(all, newNotification(res, a, false))
+    }
+    for _, a := range res.Notices {
+        all = append(all, newNotification(res, a, true))
+    }
+    for _, n := range all {
+        for _, nf := range m.Notifiers {
+            nctx, cancel := context.WithTimeout(ctx, DefaultNotifyTimeout)
+            if err := nf.Notify(nctx, n); err != nil {
+                m.logf("%s: notification failed: %v", m.Name, err)
+            }
+            cancel()
+        }
+    }
+}
+
+// ===============================================================
+
+// WebhookNotifier POSTs each notification, as JSON, to a URL.
+type WebhookNotifier struct {
+    URL    string
+    Header http.Header     // additional request headers, such as Authorization
+    Client *http.Client    // defaults to http.DefaultClient
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+
+    body, err := json.Marshal(n)
+    if err != nil {
+        return err
+    }
+    req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+    if err != nil {
+        return err
+    }
+    for k, v := range w.Header {
+        req.Header[k] = v
+    }
+    req.Header.Set("Content-Type", "application/json")
+
+    client := w.Client
+    if client == nil {
+        client = http.DefaultClient
+    }
+    resp, err := client.Do(req.WithContext(ctx))
+    if err != nil {
+        return err
+    }
+    resp.Body.Close()
+    if resp.StatusCode > 299 {
+        return fmt.Errorf("webhook '%s' returned %s", w.URL, resp.Status)
+    }
+    return nil
+}
+
+// ===============================================================
+
+// EmailNotifier sends each notification as an email, using SMTP.
+type EmailNotifier struct {
+    Server   string        // host:port of the SMTP server
+    From     string
+    To       []string
+    Username string        // if set, PLAIN authentication is used
+    Password string
+}
+
+func (e *EmailNotifier) Notify(ctx context.Context, n Notification) error {
+
+    if len(e.To) == 0 {
+        return errors.New("email has no recipients")
+    }
+    host, _, err := net.SplitHostPort(e.Server)
+    if err != nil {
+        return fmt.Errorf("invalid SMTP server '%s': %v", e.Server, err)
+    }
+    var auth smtp.Auth
+    if e.Username != "" {
+        auth = smtp.PlainAuth("", e.Username, e.Password, host)
+    }
+
+    state := "ALERT"
+    if n.Notice {
+        state = "NOTICE"
+    }
+    var msg bytes.Buffer
+    fmt.Fprintf(&msg, "From: %s\r\n", e.From)
+    fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.To, ", "))
+    fmt.Fprintf(&msg, "Subject: [heartbeat] %s %s: %s\r\n", state, n.Target, n.Kind)
+    fmt.Fprintf(&msg, "Date: %s\r\n", n.Time.Format(time.RFC1123Z))
+    fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n")
+    fmt.Fprintf(&msg, "\r\n")
+    fmt.Fprintf(&msg, "Target:   %s\r\n", n.Target)
+    fmt.Fprintf(&msg, "URL:      %s\r\n", n.URL)
+    fmt.Fprintf(&msg, "Time:     %s\r\n", n.Time.Format(time.RFC3339))
+    fmt.Fprintf(&msg, "State:    %s\r\n", n.State)
+    fmt.Fprintf(&msg, "Kind:     %s\r\n", n.Kind)
+    if n.Category != "" {
+        fmt.Fprintf(&msg, "Category: %s\r\n", n.Category)
+    }
+    fmt.Fprintf(&msg, "\r\n%s\r\n", n.Message)
+
+    // smtp.SendMail has no context, so give up waiting if need be.
+    done := make(chan error, 1)
+    go func() {
+        done <- smtp.SendMail(e.Server, auth, e.From, e.To, msg.Bytes())
+    }()
+    select {
+    case err := <-done:
+        return err
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+// ===============================================================
+
+// CommandNotifier runs a local command for each notification. The
+// notification is passed in the environment (as HEARTBEAT_TARGET,
+// HEARTBEAT_URL, HEARTBEAT_KIND, HEARTBEAT_CATEGORY, HEARTBEAT_TIME,
+// HEARTBEAT_MESSAGE, HEARTBEAT_NOTICE and HEARTBEAT_STATE) and as JSON
+// on stdin.
+type CommandNotifier struct {
+    Command string
+    Args    []string
+}
+
+func (c *CommandNotifier) Notify(ctx context.Context, n Notification) error {
+
+    body, err := json.Marshal(n)
+    if err != nil {
+        return err
+    }
+    cmd := exec.CommandContext(ctx, c.Command, c.Args...)
+    cmd.Env = append(os.Environ(),
+        "HEARTBEAT_TARGET="   + n.Target,
+        "HEARTBEAT_URL="      + n.URL,
+        "HEARTBEAT_KIND="     + string(n.Kind),
+        "HEARTBEAT_CATEGORY=" + string(n.Category),
+        "HEARTBEAT_TIME="     + n.Time.Format(time.RFC3339),
+        "HEARTBEAT_MESSAGE="  + n.Message,
+        fmt.Sprintf("HEARTBEAT_NOTICE=%v", n.Notice),
+        "HEARTBEAT_STATE="    + n.State,
+    )
+    cmd.Stdin = bytes.NewReader(body)
+    if out, err := cmd.CombinedOutput(); err != nil {
+        return fmt.Errorf("command '%s' failed: %v: %s", c.Command, err, truncate(strings.TrimSpace(string(out)), 200))
+    }
+    return nil
+}
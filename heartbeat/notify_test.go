@@ -0,0 +1,293 @@
+package heartbeat
+
+import (
+    "bufio"
+    "context"
+    "encoding/base64"
+    "encoding/json"
+    "io/ioutil"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+var testNotification = Notification{
+    Target:   "api",
+    URL:      "http://example.com/health",
+    Kind:     AlertUnreachable,
+    Category: ErrorRefused,
+    Time:     time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+    Message:  "api is unreachable",
+    State:    "CRITICAL",
+}
+
+// smtpSession is what a fakeSMTP server was told during one session.
+type smtpSession struct {
+    auth string
+    from string
+    to   []string
+    data string
+}
+
+// fakeSMTP answers a single SMTP session on a local listener, sending
+// what it was told down the returned channel.
+func fakeSMTP(t *testing.T, advertiseAuth bool) (string, <-chan smtpSession) {
+
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { ln.Close() })
+
+    sessions := make(chan smtpSession, 1)
+    go func() {
+        conn, err := ln.Accept()
+        if err != nil {
+            return
+        }
+        defer conn.Close()
+        conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+        var s smtpSession
+        r := bufio.NewReader(conn)
+        reply := func(lines ...string) {
+            for _, l := range lines {
+                conn.Write([]byte(l + "\r\n"))
+            }
+        }
+        reply("220 localhost fake ESMTP")
+        for {
+            line, err := r.ReadString('\n')
+            if err != nil {
+                return
+            }
+            line = strings.TrimRight(line, "\r\n")
+            cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+            switch cmd {
+            case "EHLO":
+                if advertiseAuth {
+                    reply("250-localhost", "250 AUTH PLAIN")
+                } else {
+                    reply("250 localhost")
+                }
+            case "AUTH":
+                s.auth = line
+                reply("235 2.7.0 Authentication successful")
+            case "MAIL":
+                s.from = line
+                reply("250 OK")
+            case "RCPT":
+                s.to = append(s.to, line)
+                reply("250 OK")
+            case "DATA":
+                reply("354 End data with <CR><LF>.<CR><LF>")
+                var data strings.Builder
+                for {
+                    l, err := r.ReadString('\n')
+                    if err != nil {
+                        return
+                    }
+                    if l == ".\r\n" {
+                        break
+                    }
+                    data.WriteString(l)
+                }
+                s.data = data.String()
+                reply("250 OK")
+            case "QUIT":
+                reply("221 Bye")
+                sessions <- s
+                return
+            default:
+                reply("502 Command not implemented")
+            }
+        }
+    }()
+    return ln.Addr().String(), sessions
+}
+
+func TestEmailNotifier(t *testing.T) {
+
+    for _, tc := range []struct {
+        name     string
+        username string
+        notice   bool
+        subject  string
+    }{
+        {"alert",     "",      false, "Subject: [heartbeat] ALERT api: unreachable"},
+        {"notice",    "",      true,  "Subject: [heartbeat] NOTICE api: unreachable"},
+        {"with auth", "alice", false, "Subject: [heartbeat] ALERT api: unreachable"},
+    } {
+        t.Run(tc.name, func(t *testing.T) {
+
+            addr, sessions := fakeSMTP(t, tc.username != "")
+            e := &EmailNotifier{
+                Server:   addr,
+                From:     "heartbeat@example.com",
+                To:       []string{"ops@example.com", "oncall@example.com"},
+                Username: tc.username,
+                Password: "secret",
+            }
+            n := testNotification
+            n.Notice = tc.notice
+            if err := e.Notify(context.Background(), n); err != nil {
+                t.Fatalf("Notify: %v", err)
+            }
+
+            var s smtpSession
+            select {
+            case s = <-sessions:
+            case <-time.After(5 * time.Second):
+                t.Fatal("no SMTP session")
+            }
+            if !strings.HasPrefix(s.from, "MAIL FROM:<heartbeat@example.com>") {
+                t.Errorf("MAIL = %q", s.from)
+            }
+            if len(s.to) != 2 || !strings.Contains(s.to[0], "<ops@example.com>") || !strings.Contains(s.to[1], "<oncall@example.com>") {
+                t.Errorf("RCPT = %q", s.to)
+            }
+            wantAuth := ""
+            if tc.username != "" {
+                wantAuth = "AUTH PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00" + tc.username + "\x00secret"))
+            }
+            if s.auth != wantAuth {
+                t.Errorf("AUTH = %q, want %q", s.auth, wantAuth)
+            }
+            for _, want := range []string{
+                tc.subject,
+                "To: ops@example.com, oncall@example.com\r\n",
+                "Target:   api\r\n",
+                "State:    CRITICAL\r\n",
+                "Category: refused\r\n",
+                "api is unreachable\r\n",
+            } {
+                if !strings.Contains(s.data, want) {
+                    t.Errorf("message lacks %q:\n%s", want, s.data)
+                }
+            }
+        })
+    }
+}
+
+func TestEmailNotifierErrors(t *testing.T) {
+
+    for _, tc := range []struct {
+        name string
+        e    EmailNotifier
+        want string
+    }{
+        {"no recipients", EmailNotifier{Server: "127.0.0.1:25"},                  "no recipients"},
+        {"bad server",    EmailNotifier{Server: "localhost", To: []string{"a@b"}}, "invalid SMTP server"},
+    } {
+        err := tc.e.Notify(context.Background(), testNotification)
+        if err == nil || !strings.Contains(err.Error(), tc.want) {
+            t.Errorf("%s: error = %v, want %q", tc.name, err, tc.want)
+        }
+    }
+}
+
+func TestWebhookNotifier(t *testing.T) {
+
+    var got Notification
+    var header http.Header
+    status := http.StatusNoContent
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+        header = r.Header
+        if r.Method != "POST" {
+            t.Errorf("method = %s", r.Method)
+        }
+        if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+            t.Errorf("body: %v", err)
+        }
+        w.WriteHeader(status)
+    }))
+    defer srv.Close()
+
+    w := &WebhookNotifier{URL: srv.URL, Header: http.Header{"Authorization": {"Bearer xyz"}}}
+    if err := w.Notify(context.Background(), testNotification); err != nil {
+        t.Fatalf("Notify: %v", err)
+    }
+    if got != testNotification {
+        t.Errorf("posted %+v, want %+v", got, testNotification)
+    }
+    if ct := header.Get("Content-Type"); ct != "application/json" {
+        t.Errorf("Content-Type = %q", ct)
+    }
+    if a := header.Get("Authorization"); a != "Bearer xyz" {
+        t.Errorf("Authorization = %q", a)
+    }
+
+    status = http.StatusInternalServerError
+    err := w.Notify(context.Background(), testNotification)
+    if err == nil || !strings.Contains(err.Error(), "500") {
+        t.Errorf("error = %v, want the 500 status", err)
+    }
+}
+
+func TestCommandNotifier(t *testing.T) {
+
+    if _, err := exec.LookPath("sh"); err != nil {
+        t.Skip("no shell")
+    }
+    dir := t.TempDir()
+    envFile, stdinFile := filepath.Join(dir, "env"), filepath.Join(dir, "stdin")
+
+    c := &CommandNotifier{
+        Command: "sh",
+        Args:    []string{"-c", `env | grep '^HEARTBEAT_' > "$1"; cat > "$2"`, "sh", envFile, stdinFile},
+    }
+    n := testNotification
+    n.Notice = true
+    if err := c.Notify(context.Background(), n); err != nil {
+        t.Fatalf("Notify: %v", err)
+    }
+
+    out, err := ioutil.ReadFile(envFile)
+    if err != nil {
+        t.Fatal(err)
+    }
+    env := map[string]string{}
+    for _, l := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+        if kv := strings.SplitN(l, "=", 2); len(kv) == 2 {
+            env[kv[0]] = kv[1]
+        }
+    }
+    for k, want := range map[string]string{
+        "HEARTBEAT_TARGET":   "api",
+        "HEARTBEAT_URL":      "http://example.com/health",
+        "HEARTBEAT_KIND":     "unreachable",
+        "HEARTBEAT_CATEGORY": "refused",
+        "HEARTBEAT_TIME":     "2024-03-01T12:30:00Z",
+        "HEARTBEAT_MESSAGE":  "api is unreachable",
+        "HEARTBEAT_NOTICE":   "true",
+        "HEARTBEAT_STATE":    "CRITICAL",
+    } {
+        if env[k] != want {
+            t.Errorf("%s = %q, want %q", k, env[k], want)
+        }
+    }
+
+    out, err = ioutil.ReadFile(stdinFile)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var got Notification
+    if err := json.Unmarshal(out, &got); err != nil {
+        t.Fatalf("stdin %q: %v", out, err)
+    }
+    if got != n {
+        t.Errorf("stdin %+v, want %+v", got, n)
+    }
+
+    c = &CommandNotifier{Command: "sh", Args: []string{"-c", "echo broken; exit 3"}}
+    err = c.Notify(context.Background(), n)
+    if err == nil || !strings.Contains(err.Error(), "broken") {
+        t.Errorf("error = %v, want the command output", err)
+    }
+}
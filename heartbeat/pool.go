@@ -0,0 +1,69 @@
+package heartbeat
+
+import (
+    "context"
+    "errors"
+)
+
+// DefaultConcurrency is the default maximum number of simultaneous fetches.
+const DefaultConcurrency = 10
+
+// Pool runs a number of Monitors concurrently, each in its own goroutine
+// and each with its own baseline.
+//
+// The number of fetches in progress at any one time may be limited, so
+// that a burst of checks falling due together does not swamp the host
+// running them (or the targets, if several share a server).
+type Pool struct {
+    monitors []*Monitor
+    limit    chan struct{}
+}
+
+// NewPool returns a Pool that allows at most max concurrent fetches.
+// A max of zero (or less) means no limit.
+func NewPool(max int) *Pool {
+
+    p := &Pool{}
+    if max > 0 {
+        p.limit = make(chan struct{}, max)
+    }
+    return p
+}
+
+// Add adds a Monitor to the pool. It must not be called once Run has started.
+func (p *Pool) Add(m *Monitor) {
+
+    m.limit = p.limit
+    p.monitors = append(p.monitors, m)
+}
+
+// Monitors returns the Monitors that have been added to the pool.
+func (p *Pool) Monitors() []*Monitor {
+
+    return p.monitors
+}
+
+// Run runs all of the Monitors until the context is cancelled, and then
+// returns its error. A Monitor that fails is logged (see Monitor.ErrorLog)
+// and stopped, but the others carry on; should every one of them fail,
+// Run returns an error instead.
+func (p *Pool) Run(ctx context.Context) error {
+
+    stopped := make(chan struct{}, len(p.monitors))
+    for _, m := range p.monitors {
+        go func(m *Monitor) {
+            if err := m.Run(ctx); ctx.Err() == nil {
+                m.logf("%s: no longer checked: %v", m.Name, err)
+            }
+            stopped <- struct{}{}
+        }(m)
+    }
+
+    for range p.monitors {
+        <-stopped
+    }
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+    return errors.New("every target has failed")
+}
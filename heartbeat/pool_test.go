@@ -0,0 +1,75 @@
+package heartbeat
+
+import (
+    "bytes"
+    "context"
+    "log"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+// A Monitor that cannot check its target is stopped, and the others
+// carry on until the context is cancelled.
+func TestPoolRun(t *testing.T) {
+
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+        w.Write([]byte("ok"))
+    }))
+    defer srv.Close()
+
+    var logged bytes.Buffer
+    bad := NewMonitor("http://bad host/")
+    bad.ErrorLog = log.New(&logged, "", 0)
+
+    checked := make(chan struct{}, 10)
+    good := NewMonitor(srv.URL)
+    good.Interval = time.Millisecond
+    good.OnResult = func(*Result) {
+
+        select {
+        case checked <- struct{}{}:
+        default:
+        }
+    }
+
+    p := NewPool(1)
+    p.Add(bad)
+    p.Add(good)
+    ctx, cancel := context.WithCancel(context.Background())
+    errc := make(chan error, 1)
+    go func() {
+        errc <- p.Run(ctx)
+    }()
+
+    for i := 0; i < 3; i++ {
+        select {
+        case <-checked:
+        case err := <-errc:
+            t.Fatalf("Run returned %v while a target was still being checked", err)
+        case <-time.After(5 * time.Second):
+            t.Fatal("target not checked")
+        }
+    }
+    cancel()
+    if err := <-errc; err != context.Canceled {
+        t.Errorf("Run returned %v, want %v", err, context.Canceled)
+    }
+    if !strings.Contains(logged.String(), "no longer checked") {
+        t.Errorf("logged %q, want the bad target to be stopped", logged.String())
+    }
+}
+
+func TestPoolRunAllFailed(t *testing.T) {
+
+    m := NewMonitor("http://bad host/")
+    m.ErrorLog = log.New(&bytes.Buffer{}, "", 0)
+    p := NewPool(0)
+    p.Add(m)
+    if err := p.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "every target has failed") {
+        t.Errorf("Run returned %v", err)
+    }
+}
@@ -0,0 +1,239 @@
+package heartbeat
+
+import (
+    "bufio"
+    "bytes"
+    "crypto/tls"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "net"
+    "net/http"
+    "net/http/httptrace"
+    "strings"
+    "time"
+)
+
+// Protocol pins the version of HTTP used to fetch a target. When a
+// Protocol is specified the server is expected to answer with the same
+// version, otherwise an alert is raised.
+//
+// REQUIRES the http.Protocols type from Go 1.24
+type Protocol string
+
+const (
+    ProtocolAny    Protocol = ""           // whatever the transport negotiates
+    ProtocolHTTP10 Protocol = "HTTP/1.0"   // no keep-alive
+    ProtocolHTTP11 Protocol = "HTTP/1.1"
+    ProtocolH2     Protocol = "h2"         // HTTP/2 over TLS
+    ProtocolH2C    Protocol = "h2c"        // HTTP/2 over cleartext TCP (prior knowledge)
+)
+
+// ParseProtocol accepts "HTTP/1.0", "HTTP/1.1", "h2" or "h2c" (or "1.0",
+// "1.1" and "2"), ignoring case. An empty string is ProtocolAny.
+func ParseProtocol(s string) (Protocol, error) {
+
+    switch strings.ToLower(strings.TrimSpace(s)) {
+    case "":
+        return ProtocolAny, nil
+    case "http/1.0", "1.0":
+        return ProtocolHTTP10, nil
+    case "http/1.1", "1.1":
+        return ProtocolHTTP11, nil
+    case "h2", "http/2", "http/2.0", "2", "2.0":
+        return ProtocolH2, nil
+    case "h2c":
+        return ProtocolH2C, nil
+    }
+    return ProtocolAny, fmt.Errorf("unknown protocol '%s' (use HTTP/1.0, HTTP/1.1, h2 or h2c)", s)
+}
+
+// Version returns the major and minor version the server should answer with.
+func (p Protocol) Version() (major, minor int) {
+
+    switch p {
+    case ProtocolHTTP10:
+        return 1, 0
+    case ProtocolHTTP11:
+        return 1, 1
+    case ProtocolH2, ProtocolH2C:
+        return 2, 0
+    }
+    return 0, 0
+}
+
+// checkURL reports whether the protocol can be used for the URL scheme.
+func (p Protocol) checkURL(scheme string) error {
+
+    switch {
+    case p == ProtocolH2 && scheme != "https":
+        return fmt.Errorf("protocol h2 needs an https URL (use h2c for http)")
+    case p == ProtocolH2C && scheme != "http":
+        return fmt.Errorf("protocol h2c needs an http URL (use h2 for https)")
+    }
+    return nil
+}
+
+// newTransport returns a RoundTripper that only speaks the protocol,
+// keeping up to idle connections per host for reuse (0 for the default)
+// and connecting with the TLS configuration (nil for the default).
+func newTransport(p Protocol, idle int, config *tls.Config) http.RoundTripper {
+
+    if p == ProtocolHTTP10 {
+        return &http10Transport{
+            dialer: &net.Dialer{Timeout: 30 * time.Second},
+            config: config,
+        }
+    }
+
+    t := http.DefaultTransport.(*http.Transport).Clone()
+    if config != nil {
+        t.TLSClientConfig = config.Clone()
+    }
+    if idle > 0 {
+        t.MaxIdleConns        = idle
+        t.MaxIdleConnsPerHost = idle
+    }
+    switch p {
+    case ProtocolHTTP11:
+        t.Protocols = new(http.Protocols)
+        t.Protocols.SetHTTP1(true)
+    case ProtocolH2:
+        t.Protocols = new(http.Protocols)
+        t.Protocols.SetHTTP2(true)
+    case ProtocolH2C:
+        t.Protocols = new(http.Protocols)
+        t.Protocols.SetUnencryptedHTTP2(true)
+    }
+    return t
+}
+
+// http10Transport sends HTTP/1.0 requests, one connection per request.
+// (The standard transport always sends HTTP/1.1 or HTTP/2.)
+type http10Transport struct {
+    dialer *net.Dialer
+    config *tls.Config      // nil for the default
+}
+
+func (t *http10Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+    ctx := req.Context()
+    host := req.URL.Host
+    if req.URL.Port() == "" {
+        if req.URL.Scheme == "https" {
+            host = net.JoinHostPort(req.URL.Hostname(), "443")
+        } else {
+            host = net.JoinHostPort(req.URL.Hostname(), "80")
+        }
+    }
+
+    conn, err := t.dialer.DialContext(ctx, "tcp", host)
+    if err != nil {
+        return nil, err
+    }
+    if deadline, ok := ctx.Deadline(); ok {
+        conn.SetDeadline(deadline)
+    }
+    trace := httptrace.ContextClientTrace(ctx)
+    if req.URL.Scheme == "https" {
+        if trace != nil && trace.TLSHandshakeStart != nil {
+            trace.TLSHandshakeStart()
+        }
+        config := &tls.Config{}
+        if t.config != nil {
+            config = t.config.Clone()
+        }
+        if config.ServerName == "" {
+            config.ServerName = req.URL.Hostname()
+        }
+        tc := tls.Client(conn, config)
+        err := tc.HandshakeContext(ctx)
+        if trace != nil && trace.TLSHandshakeDone != nil {
+            trace.TLSHandshakeDone(tc.ConnectionState(), err)
+        }
+        if err != nil {
+            conn.Close()
+            return nil, err
+        }
+        conn = tc
+    }
+    var state *tls.ConnectionState
+    if tc, ok := conn.(*tls.Conn); ok {
+        cs := tc.ConnectionState()
+        state = &cs
+    }
+    if trace != nil && trace.GotConn != nil {
+        trace.GotConn(httptrace.GotConnInfo{Conn: conn})
+    }
+
+    err = writeHTTP10(conn, req)
+    if trace != nil && trace.WroteRequest != nil {
+        trace.WroteRequest(httptrace.WroteRequestInfo{Err: err})
+    }
+    if err != nil {
+        conn.Close()
+        return nil, err
+    }
+
+    br := bufio.NewReader(conn)
+    if _, err := br.Peek(1); err != nil {
+        conn.Close()
+        return nil, err
+    }
+    if trace != nil && trace.GotFirstResponseByte != nil {
+        trace.GotFirstResponseByte()
+    }
+    resp, err := http.ReadResponse(br, req)
+    if err != nil {
+        conn.Close()
+        return nil, err
+    }
+    resp.Body = &connBody{ReadCloser: resp.Body, conn: conn}
+    resp.TLS  = state
+    return resp, nil
+}
+
+func writeHTTP10(w io.Writer, req *http.Request) error {
+
+    var body []byte
+    if req.Body != nil {
+        var err error
+        if body, err = ioutil.ReadAll(req.Body); err != nil {
+            return err
+        }
+        req.Body.Close()
+    }
+
+    var b bytes.Buffer
+    fmt.Fprintf(&b, "%s %s HTTP/1.0\r\n", req.Method, req.URL.RequestURI())
+    host := req.Host
+    if host == "" {
+        host = req.URL.Host
+    }
+    fmt.Fprintf(&b, "Host: %s\r\n", host)
+    if req.Header.Get("User-Agent") == "" {
+        b.WriteString("User-Agent: Go-http-client/1.0\r\n")
+    }
+    if len(body) > 0 {
+        fmt.Fprintf(&b, "Content-Length: %d\r\n", len(body))
+    }
+    req.Header.Write(&b)
+    b.WriteString("\r\n")
+    b.Write(body)
+
+    _, err := w.Write(b.Bytes())
+    return err
+}
+
+// connBody closes the connection along with the response body.
+type connBody struct {
+    io.ReadCloser
+    conn net.Conn
+}
+
+func (b *connBody) Close() error {
+
+    err := b.ReadCloser.Close()
+    b.conn.Close()
+    return err
+}
@@ -0,0 +1,130 @@
+package heartbeat
+
+import (
+    "fmt"
+    "net/http"
+    "strings"
+    "time"
+)
+
+// DefaultMaxRedirects is the number of redirects followed by default.
+const DefaultMaxRedirects = 10
+
+// RedirectPolicy decides how redirects are followed, and which chain
+// of redirects is expected.
+//
+// If Expect is not nil, each chain must match it: the URLs redirected
+// to, in order (so an empty list means no redirects at all). Otherwise
+// each chain is compared with the one before, unless IgnoreChanges is
+// set - so that (say) http:// no longer redirecting to https://, or a
+// new hop through a CDN, is noticed.
+type RedirectPolicy struct {
+    Max           int      `json:"max"`             // most redirects followed (0 = DefaultMaxRedirects)
+    NoFollow      bool     `json:"no_follow"`       // do not follow redirects at all
+    Expect        []string `json:"expect"`          // expected chain of URLs redirected to
+    IgnoreChanges bool     `json:"ignore_changes"`  // do not compare with the previous chain
+}
+
+// Hop is one request of a fetch: the first, or one made to follow a
+// redirect.
+type Hop struct {
+    URL        string
+    StatusCode int             // zero if the request failed
+    Location   string          // where it redirected to (as an absolute URL), if anywhere
+    Time       time.Duration   // until the response headers arrived
+    DNS        time.Duration   // total of its DNS lookups
+    Lookups    int
+}
+
+// Validate checks that the redirect settings are usable.
+func (p *RedirectPolicy) Validate() error {
+
+    if p.Max < 0 {
+        return fmt.Errorf("max redirects must not be negative")
+    }
+    for _, u := range p.Expect {
+        if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+            return fmt.Errorf("invalid expected redirect '%s' (use an absolute URL)", u)
+        }
+    }
+    return nil
+}
+
+func (p *RedirectPolicy) max() int {
+
+    if p.Max > 0 {
+        return p.Max
+    }
+    return DefaultMaxRedirects
+}
+
+// checkRedirect is the http.Client's CheckRedirect function.
+func (p *RedirectPolicy) checkRedirect(req *http.Request, via []*http.Request) error {
+
+    if p.NoFollow {
+        return http.ErrUseLastResponse
+    }
+    if len(via) > p.max() {
+        return fmt.Errorf("stopped after %d redirects", p.max())
+    }
+    return nil
+}
+
+// Chain returns the URLs redirected to during the fetch, in order -
+// including the last, if that redirect was not followed.
+func (r *Result) Chain() []string {
+
+    var chain []string
+    for _, h := range r.Hops {
+        if h.Location != "" {
+            chain = append(chain, h.Location)
+        }
+    }
+    return chain
+}
+
+// chainString describes a chain of redirects, as in 'http://a -> https://a/'.
+func chainString(first string, chain []string) string {
+
+    if len(chain) == 0 {
+        return "no redirects"
+    }
+    return strings.Join(append([]string{first}, chain...), " -> ")
+}
+
+// checkRedirects compares the chain of redirects with the expected
+// chain, or else the previous one.
+func (m *Monitor) checkRedirects(res *Result) {
+
+    for i, h := range res.Hops {
+        m.debugf("hop %d: %s %d %s (%d ms, %d DNS lookups in %d ms)\n", i, h.URL, h.StatusCode, h.Location,
+                 int(h.Time / time.Millisecond), h.Lookups, int(h.DNS / time.Millisecond))
+    }
+
+    chain := res.Chain()
+    switch {
+    case m.Redirects.Expect != nil:
+        if !sameChain(chain, m.Redirects.Expect) {
+            res.alert(AlertRedirect, "expected %s, found %s",
+                      chainString(m.URL, m.Redirects.Expect), chainString(m.URL, chain))
+        }
+    case m.Redirects.IgnoreChanges:
+    case m.chain != nil && !sameChain(chain, *m.chain):
+        res.alert(AlertRedirect, "redirect chain changed from %s to %s",
+                  chainString(m.URL, *m.chain), chainString(m.URL, chain))
+    }
+    m.chain = &chain
+}
+
+func sameChain(a, b []string) bool {
+
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if a[i] != b[i] {
+            return false
+        }
+    }
+    return true
+}
@@ -0,0 +1,134 @@
+package heartbeat
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync/atomic"
+    "testing"
+)
+
+// newRedirectServer starts a server whose /start redirects to /a, or to
+// /b while moved is set.
+func newRedirectServer(t *testing.T, moved *int32) *httptest.Server {
+
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+        switch {
+        case r.URL.Path == "/start" && atomic.LoadInt32(moved) != 0:
+            http.Redirect(w, r, "/b", http.StatusFound)
+        case r.URL.Path == "/start":
+            http.Redirect(w, r, "/a", http.StatusMovedPermanently)
+        case r.URL.Path == "/loop":
+            http.Redirect(w, r, "/loop", http.StatusFound)
+        default:
+            w.Write([]byte("ok"))
+        }
+    }))
+    t.Cleanup(srv.Close)
+    return srv
+}
+
+// redirectAlerts checks the Monitor once, returning the messages of the
+// redirect alerts raised.
+func redirectAlerts(t *testing.T, m *Monitor) (*Result, []string) {
+
+    res, err := m.CheckOnce(context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+    var alerts []string
+    for _, a := range res.Alerts {
+        if a.Kind == AlertRedirect {
+            alerts = append(alerts, a.Message)
+        }
+    }
+    return res, alerts
+}
+
+func TestRedirectChainChanged(t *testing.T) {
+
+    var moved int32
+    srv := newRedirectServer(t, &moved)
+    m := NewMonitor(srv.URL + "/start")
+
+    res, alerts := redirectAlerts(t, m)
+    if len(alerts) > 0 {
+        t.Errorf("alerts %q on the first check", alerts)
+    }
+    if chain := res.Chain(); len(chain) != 1 || chain[0] != srv.URL + "/a" {
+        t.Errorf("chain %q", chain)
+    }
+    if _, alerts := redirectAlerts(t, m); len(alerts) > 0 {
+        t.Errorf("alerts %q for the same chain", alerts)
+    }
+
+    atomic.StoreInt32(&moved, 1)
+    want := "redirect chain changed from " + srv.URL + "/start -> " + srv.URL + "/a to " + srv.URL + "/start -> " + srv.URL + "/b"
+    if _, alerts := redirectAlerts(t, m); len(alerts) != 1 || alerts[0] != want {
+        t.Errorf("alerts %q, want %q", alerts, want)
+    }
+    if _, alerts := redirectAlerts(t, m); len(alerts) > 0 {
+        t.Errorf("alerts %q once the change was reported", alerts)
+    }
+
+    m.Redirects.IgnoreChanges = true
+    atomic.StoreInt32(&moved, 0)
+    if _, alerts := redirectAlerts(t, m); len(alerts) > 0 {
+        t.Errorf("alerts %q while ignoring changes", alerts)
+    }
+}
+
+func TestRedirectExpect(t *testing.T) {
+
+    var moved int32
+    srv := newRedirectServer(t, &moved)
+
+    for _, tc := range []struct {
+        path   string
+        expect []string
+        alert  string
+    }{
+        {"/start",  []string{srv.URL + "/a"},   ""},
+        {"/start",  []string{srv.URL + "/b"},   "expected " + srv.URL + "/start -> " + srv.URL + "/b, found " + srv.URL + "/start -> " + srv.URL + "/a"},
+        {"/a",      []string{},                 ""},
+        {"/start",  []string{},                 "expected no redirects, found " + srv.URL + "/start -> " + srv.URL + "/a"},
+    } {
+        m := NewMonitor(srv.URL + tc.path)
+        m.Redirects.Expect = tc.expect
+        _, alerts := redirectAlerts(t, m)
+        if (tc.alert == "" && len(alerts) > 0) || (tc.alert != "" && (len(alerts) != 1 || alerts[0] != tc.alert)) {
+            t.Errorf("%s %q: alerts %q, want %q", tc.path, tc.expect, alerts, tc.alert)
+        }
+    }
+}
+
+func TestRedirectFollow(t *testing.T) {
+
+    var moved int32
+    srv := newRedirectServer(t, &moved)
+
+    m := NewMonitor(srv.URL + "/start")
+    m.Redirects.NoFollow = true
+    res, _ := redirectAlerts(t, m)
+    if res.Err != nil || !res.Redirected || res.StatusCode != http.StatusMovedPermanently || len(res.Hops) != 1 {
+        t.Errorf("not followed: error %v, status %d, %d hops", res.Err, res.StatusCode, len(res.Hops))
+    }
+
+    m = NewMonitor(srv.URL + "/loop")
+    m.Redirects.Max = 3
+    res, _ = redirectAlerts(t, m)
+    if res.Err == nil || !strings.Contains(res.Err.Error(), "stopped after 3 redirects") {
+        t.Errorf("loop: error %v", res.Err)
+    }
+
+    m.Redirects.Max = -1
+    if err := m.Validate(); err == nil {
+        t.Error("negative max redirects accepted")
+    }
+    m.Redirects = RedirectPolicy{Expect: []string{"/a"}}
+    if err := m.Validate(); err == nil || !strings.Contains(err.Error(), "use an absolute URL") {
+        t.Errorf("relative expected redirect: error %v", err)
+    }
+}
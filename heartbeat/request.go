@@ -0,0 +1,104 @@
+package heartbeat
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "io"
+    "net/http"
+    "strings"
+)
+
+// Auth holds the credentials sent with every request: either a user
+// name and password (Basic authentication) or a Bearer token.
+type Auth struct {
+    Username    string `json:"username"`
+    Password    string `json:"password"`
+    BearerToken string `json:"bearer_token"`
+}
+
+// Validate checks that the credentials are usable.
+func (a *Auth) Validate() error {
+
+    switch {
+    case a.Username != "" && a.BearerToken != "":
+        return fmt.Errorf("use either a user name and password or a bearer token, not both")
+    case a.Username == "" && a.Password != "":
+        return fmt.Errorf("password needs a user name")
+    case a.Username == "" && a.BearerToken == "":
+        return fmt.Errorf("missing user name or bearer token")
+    case strings.Contains(a.Username, ":"):
+        return fmt.Errorf("user name must not contain ':'")
+    case strings.ContainsAny(a.BearerToken, " \t\r\n"):
+        return fmt.Errorf("bearer token must not contain spaces")
+    }
+    return nil
+}
+
+// validateRequest checks the request definition, so that a target that
+// cannot be requested is rejected before polling starts.
+func (m *Monitor) validateRequest() error {
+
+    for name, values := range m.Header {
+        if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+            return fmt.Errorf("invalid header name '%s'", name)
+        }
+        for _, v := range values {
+            if strings.ContainsAny(v, "\r\n") {
+                return fmt.Errorf("invalid value for header %s (line breaks are not allowed)", name)
+            }
+        }
+    }
+    if strings.ContainsAny(m.UserAgent, "\r\n") {
+        return fmt.Errorf("invalid user agent (line breaks are not allowed)")
+    }
+    if m.Auth != nil {
+        if err := m.Auth.Validate(); err != nil {
+            return err
+        }
+    }
+    if _, err := m.newRequest(context.Background()); err != nil {
+        return fmt.Errorf("invalid request: %v", err)
+    }
+    return nil
+}
+
+// newRequest returns the request for the target: its Method (GET by
+// default), URL (with the Query added), Header, Auth, UserAgent and Body.
+func (m *Monitor) newRequest(ctx context.Context) (*http.Request, error) {
+
+    method := m.Method
+    if method == "" {
+        method = http.MethodGet
+    }
+    var body io.Reader
+    if m.Body != nil {
+        body = bytes.NewReader(m.Body)      // so that redirects can resend it
+    }
+
+    req, err := http.NewRequestWithContext(ctx, method, m.URL, body)
+    if err != nil {
+        return nil, err
+    }
+    if len(m.Query) > 0 {
+        q := req.URL.Query()
+        for k, v := range m.Query {
+            q[k] = append(q[k], v...)
+        }
+        req.URL.RawQuery = q.Encode()
+    }
+    for k, v := range m.Header {
+        req.Header[k] = v
+    }
+    if m.UserAgent != "" {
+        req.Header.Set("User-Agent", m.UserAgent)
+    }
+    if m.Auth != nil {
+        if m.Auth.BearerToken != "" {
+            req.Header.Set("Authorization", "Bearer " + m.Auth.BearerToken)
+        } else {
+            req.SetBasicAuth(m.Auth.Username, m.Auth.Password)
+        }
+    }
+    return req, nil
+}
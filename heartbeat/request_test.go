@@ -0,0 +1,86 @@
+package heartbeat
+
+import (
+    "context"
+    "io/ioutil"
+    "net/http"
+    "net/url"
+    "strings"
+    "testing"
+)
+
+func TestNewRequest(t *testing.T) {
+
+    m := NewMonitor("http://localhost/api?page=2")
+    m.Method = http.MethodPost
+    m.Query = url.Values{"page": {"3"}, "q": {"a b&c"}}
+    m.Header = http.Header{"X-Trace": {"1", "2"}, "User-Agent": {"ignored"}}
+    m.UserAgent = "heartbeat-test"
+    m.Body = []byte(`{"ping":true}`)
+    m.Auth = &Auth{Username: "alice", Password: "s3cr:et"}
+    if err := m.Validate(); err != nil {
+        t.Fatal(err)
+    }
+
+    req, err := m.newRequest(context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if req.Method != http.MethodPost {
+        t.Errorf("method %s", req.Method)
+    }
+    if q := req.URL.Query(); len(q["page"]) != 2 || q["page"][0] != "2" || q["page"][1] != "3" || q.Get("q") != "a b&c" {
+        t.Errorf("query %q", req.URL.RawQuery)
+    }
+    if h := req.Header["X-Trace"]; len(h) != 2 || req.UserAgent() != "heartbeat-test" {
+        t.Errorf("headers %v", req.Header)
+    }
+    if user, password, ok := req.BasicAuth(); !ok || user != "alice" || password != "s3cr:et" {
+        t.Errorf("basic auth %q %q %v", user, password, ok)
+    }
+
+    // The body can be read again, as when a redirect resends it.
+    for i := 0; i < 2; i++ {
+        body, err := ioutil.ReadAll(req.Body)
+        if err != nil || string(body) != `{"ping":true}` || req.ContentLength != int64(len(body)) {
+            t.Errorf("body %q (%v), length %d", body, err, req.ContentLength)
+        }
+        if req.Body, err = req.GetBody(); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    m.Auth = &Auth{BearerToken: "abc.def"}
+    m.Method, m.Body = "", nil
+    if req, err = m.newRequest(context.Background()); err != nil {
+        t.Fatal(err)
+    }
+    if req.Method != http.MethodGet || req.Body != nil || req.Header.Get("Authorization") != "Bearer abc.def" {
+        t.Errorf("%s request, body %v, authorization %q", req.Method, req.Body, req.Header.Get("Authorization"))
+    }
+}
+
+func TestValidateRequest(t *testing.T) {
+
+    for _, tc := range []struct {
+        name string
+        set  func(m *Monitor)
+        err  string
+    }{
+        {"header name",     func(m *Monitor) { m.Header = http.Header{"X Bad": {"1"}} },   "invalid header name 'X Bad'"},
+        {"header value",    func(m *Monitor) { m.Header = http.Header{"X-Bad": {"1\r\nX-Evil: 2"}} }, "line breaks are not allowed"},
+        {"user agent",      func(m *Monitor) { m.UserAgent = "a\nb" },                     "invalid user agent"},
+        {"both",            func(m *Monitor) { m.Auth = &Auth{Username: "a", BearerToken: "t"} }, "not both"},
+        {"password",        func(m *Monitor) { m.Auth = &Auth{Password: "p"} },             "password needs a user name"},
+        {"empty",           func(m *Monitor) { m.Auth = &Auth{} },                          "missing user name or bearer token"},
+        {"user name",       func(m *Monitor) { m.Auth = &Auth{Username: "a:b"} },           "must not contain ':'"},
+        {"token",           func(m *Monitor) { m.Auth = &Auth{BearerToken: "a b"} },        "must not contain spaces"},
+        {"method",          func(m *Monitor) { m.Method = "GET /" },                        "invalid request"},
+    } {
+        m := NewMonitor("http://localhost/")
+        tc.set(m)
+        if err := m.validateRequest(); err == nil || !strings.Contains(err.Error(), tc.err) {
+            t.Errorf("%s: error %v, want %q", tc.name, err, tc.err)
+        }
+    }
+}
@@ -0,0 +1,456 @@
+package heartbeat
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/http/cookiejar"
+    "net/url"
+    "regexp"
+    "strings"
+    "time"
+)
+
+// Scenario is a synthetic transaction: a series of requests, such as
+// log in, fetch a token, call the API and log out, made in turn and
+// reported as one check. The steps share a cookie jar (a new one for
+// each check), and values captured from the response to one step may
+// be used by the steps after it as ${name} - in the URL, method,
+// headers, query, body and credentials.
+//
+// A step fails if its request fails, one of its assertions fails or a
+// value cannot be captured; the check stops there, and its alerts (and
+// error) identify the step. The time and size baselines apply to the
+// whole scenario: its total round trip time, and the total size of the
+// responses.
+type Scenario struct {
+    Vars  map[string]string `json:"vars"`      // variables defined before the first step
+    Steps []Step            `json:"steps"`
+}
+
+// Step is one request of a Scenario.
+//
+// Captures name the variable set and where its value is found, as in
+//
+//    "token":   "json:$.access_token"
+//    "csrf":    "header:X-CSRF-Token"
+//    "session": "cookie:SESSIONID"
+//    "order":   "regexp:order-([0-9]+)"
+//
+// (a regexp captures its first group, if it has one, or else the whole
+// match).
+type Step struct {
+    Name    string            `json:"name"`      // default "step N"
+    URL     string            `json:"url"`
+    Method  string            `json:"method"`    // default GET
+    Headers map[string]string `json:"headers"`   // as well as the Monitor's
+    Query   map[string]string `json:"query"`     // added to the URL's query string
+    Body    string            `json:"body"`
+    Auth    *Auth             `json:"auth"`      // default the Monitor's
+    Assert  *Assertions       `json:"assert"`
+    Capture map[string]string `json:"capture"`   // variable name -> source
+
+    captures []*capture
+}
+
+// capture is a parsed Step capture.
+type capture struct {
+    name   string
+    source string                   // json, header, cookie or regexp
+    arg    string
+    path   []jsonStep
+    re     *regexp.Regexp
+}
+
+var variable = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Validate checks the steps, and that every variable is defined (or
+// captured) before it is used.
+func (s *Scenario) Validate() error {
+
+    if len(s.Steps) == 0 {
+        return fmt.Errorf("scenario has no steps")
+    }
+    defined := map[string]bool{}
+    for name := range s.Vars {
+        if !variable.MatchString("${" + name + "}") {
+            return fmt.Errorf("invalid variable name '%s'", name)
+        }
+        defined[name] = true
+    }
+    for i := range s.Steps {
+        step := &s.Steps[i]
+        if err := step.validate(defined); err != nil {
+            return fmt.Errorf("%s: %v", step.label(i), err)
+        }
+        for _, c := range step.captures {
+            defined[c.name] = true
+        }
+    }
+    return nil
+}
+
+func (step *Step) validate(defined map[string]bool) error {
+
+    if step.URL == "" {
+        return fmt.Errorf("missing url")
+    }
+    texts := []string{step.URL, step.Method, step.Body}
+    for _, v := range step.Headers {
+        texts = append(texts, v)
+    }
+    for _, v := range step.Query {
+        texts = append(texts, v)
+    }
+    if step.Auth != nil {
+        if err := step.Auth.Validate(); err != nil {
+            return err
+        }
+        texts = append(texts, step.Auth.Username, step.Auth.Password, step.Auth.BearerToken)
+    }
+    for _, text := range texts {
+        for _, match := range variable.FindAllStringSubmatch(text, -1) {
+            if !defined[match[1]] {
+                return fmt.Errorf("undefined variable '%s'", match[1])
+            }
+        }
+    }
+    if !variable.MatchString(step.URL) {
+        u, err := url.Parse(step.URL)
+        if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+            return fmt.Errorf("invalid URL '%s'", step.URL)
+        }
+    }
+    if step.Assert != nil {
+        if err := step.Assert.Validate(); err != nil {
+            return err
+        }
+    }
+
+    step.captures = nil
+    for _, name := range sortedNames(step.Capture) {
+        c, err := parseCapture(name, step.Capture[name])
+        if err != nil {
+            return err
+        }
+        step.captures = append(step.captures, c)
+    }
+    return nil
+}
+
+// parseCapture parses the source of a capture, as in "json:$.token".
+func parseCapture(name, source string) (*capture, error) {
+
+    if !variable.MatchString("${" + name + "}") {
+        return nil, fmt.Errorf("invalid variable name '%s'", name)
+    }
+    c := &capture{name: name}
+    i := strings.Index(source, ":")
+    if i > 0 {
+        c.source, c.arg = source[:i], source[i + 1:]
+    }
+    var err error
+    switch {
+    case c.arg == "":
+        return nil, fmt.Errorf("invalid capture '%s' (use json:path, header:name, cookie:name or regexp:expr)", source)
+    case c.source == "json":
+        c.path, err = parseJSONPath(c.arg)
+    case c.source == "regexp":
+        if c.re, err = regexp.Compile(c.arg); err != nil {
+            err = fmt.Errorf("invalid regexp '%s': %v", c.arg, err)
+        }
+    case c.source == "header" || c.source == "cookie":
+    default:
+        return nil, fmt.Errorf("unknown capture source '%s' (use json, header, cookie or regexp)", c.source)
+    }
+    return c, err
+}
+
+// label identifies the i'th step (counting from 0) in messages.
+func (step *Step) label(i int) string {
+
+    if step.Name != "" {
+        return fmt.Sprintf("step %d (%s)", i + 1, step.Name)
+    }
+    return fmt.Sprintf("step %d", i + 1)
+}
+
+// scenarioIgnores returns the name of a setting of the Monitor that its
+// Scenario would ignore, if any: the steps are not checked for a value,
+// a protocol or a chain of redirects.
+func (m *Monitor) scenarioIgnores() string {
+
+    switch {
+    case m.Value != nil:
+        return "value"
+    case m.Protocol != ProtocolAny:
+        return "protocol"
+    case len(m.Redirects.Expect) > 0:
+        return "redirects.expect"
+    case m.Redirects.IgnoreChanges:
+        return "redirects.ignore_changes"
+    }
+    return ""
+}
+
+// expand replaces the variables in s with their values.
+func expand(s string, vars map[string]string) (string, error) {
+
+    var err error
+    s = variable.ReplaceAllStringFunc(s, func(v string) string {
+        name := v[2:len(v) - 1]
+        value, ok := vars[name]
+        if !ok && err == nil {
+            err = fmt.Errorf("undefined variable '%s'", name)
+        }
+        return value
+    })
+    return s, err
+}
+
+// stepMonitor returns a Monitor to fetch a step with: the Monitor's
+// own settings, with the step's request (its variables expanded) and
+// assertions.
+func (m *Monitor) stepMonitor(step *Step, vars map[string]string, jar http.CookieJar) (*Monitor, error) {
+
+    sm := &Monitor{
+        Name:      m.Name,
+        Timeout:   m.Timeout,
+        Protocol:  m.Protocol,
+        Redirects: m.Redirects,
+        TLS:       m.TLS,
+        Header:    http.Header{},
+        Auth:      m.Auth,
+        UserAgent: m.UserAgent,
+        Assert:    step.Assert,
+        Verbose:   m.Verbose,
+        jar:       jar,
+        keepBody:  len(step.captures) > 0,
+    }
+    var errs []error
+    x := func(s string) string {
+        s, err := expand(s, vars)
+        if err != nil {
+            errs = append(errs, err)
+        }
+        return s
+    }
+
+    sm.URL    = x(step.URL)
+    sm.Method = strings.ToUpper(x(step.Method))
+    if sm.Method == "" {
+        sm.Method = http.MethodGet
+    }
+    for k, v := range m.Header {
+        sm.Header[k] = v
+    }
+    for k, v := range step.Headers {
+        sm.Header.Set(k, x(v))
+    }
+    for k, v := range step.Query {
+        if sm.Query == nil {
+            sm.Query = url.Values{}
+        }
+        sm.Query.Set(k, x(v))
+    }
+    if step.Body != "" {
+        sm.Body = []byte(x(step.Body))
+    }
+    if step.Auth != nil {
+        sm.Auth = &Auth{Username: x(step.Auth.Username), Password: x(step.Auth.Password),
+                        BearerToken: x(step.Auth.BearerToken)}
+    }
+    if len(errs) > 0 {
+        return nil, errs[0]
+    }
+    if u, err := url.Parse(sm.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+        return nil, fmt.Errorf("invalid URL '%s'", sm.URL)
+    }
+    return sm, sm.validateRequest()
+}
+
+// capture sets the step's variables from its response.
+func (step *Step) capture(res *Result, body []byte, jar http.CookieJar, vars map[string]string) error {
+
+    var doc interface{}
+    for _, c := range step.captures {
+        var value string
+        switch c.source {
+        case "json":
+            if doc == nil {
+                dec := json.NewDecoder(bytes.NewReader(body))
+                dec.UseNumber()
+                if err := dec.Decode(&doc); err != nil {
+                    return fmt.Errorf("unable to capture '%s': body is not JSON: %v", c.name, err)
+                }
+            }
+            v, err := evalJSONPath(doc, c.path)
+            if err != nil {
+                return fmt.Errorf("unable to capture '%s': %v", c.name, err)
+            }
+            if s, ok := v.(string); ok {
+                value = s
+            } else {
+                b, _ := json.Marshal(v)
+                value = string(b)
+            }
+        case "header":
+            values, ok := res.Header[http.CanonicalHeaderKey(c.arg)]
+            if !ok {
+                return fmt.Errorf("unable to capture '%s': header %s is missing", c.name, c.arg)
+            }
+            value = values[0]
+        case "cookie":
+            found := false
+            if u, err := url.Parse(res.Hops[len(res.Hops) - 1].URL); err == nil {
+                for _, cookie := range jar.Cookies(u) {
+                    if cookie.Name == c.arg {
+                        value, found = cookie.Value, true
+                    }
+                }
+            }
+            if !found {
+                return fmt.Errorf("unable to capture '%s': no cookie %s", c.name, c.arg)
+            }
+        case "regexp":
+            match := c.re.FindSubmatch(body)
+            if match == nil {
+                return fmt.Errorf("unable to capture '%s': body does not match '%s'", c.name, c.re)
+            }
+            value = string(match[0])
+            if len(match) > 1 {
+                value = string(match[1])
+            }
+        }
+        vars[c.name] = value
+    }
+    return nil
+}
+
+// checkScenario runs the steps of the Monitor's Scenario in turn, as
+// one check, stopping at the first that fails.
+func (m *Monitor) checkScenario(ctx context.Context) (*Result, error) {
+
+    base, err := m.roundTripper()
+    if err != nil {
+        return nil, err
+    }
+    if base == nil {
+        base = http.DefaultTransport    // shared by the steps
+    }
+    jar, err := cookiejar.New(nil)
+    if err != nil {
+        return nil, err
+    }
+    vars := map[string]string{}
+    for k, v := range m.Scenario.Vars {
+        vars[k] = v
+    }
+
+    steps := m.Scenario.Steps
+    for i := range steps {
+        if len(steps[i].captures) != len(steps[i].Capture) {
+            if err := m.Scenario.Validate(); err != nil {
+                return nil, err
+            }
+        }
+    }
+
+    if len(m.stepTLS) != len(steps) {
+        m.stepTLS = make([]uint16, len(steps))  // the steps may be on different hosts
+    }
+
+    res := &Result{Name: m.Name, URL: m.URL, Start: time.Now()}
+    completed := true
+    for i := range steps {
+        step := &steps[i]
+        label := step.label(i)
+
+        sm, err := m.stepMonitor(step, vars, jar)
+        var sres *Result
+        var body []byte
+        if err == nil {
+            m.debugf("%s: %s %s\n", label, sm.Method, sm.URL)
+            sres, body, err = sm.fetch(ctx, base)
+        }
+        if err != nil {
+            // the request could not be made, perhaps because of a captured value
+            sres = &Result{URL: step.URL, Start: time.Now(), Err: err, Category: ErrorOther}
+        }
+        sres.Name = label
+        res.addStep(sres)
+
+        switch {
+        case sres.Err != nil:
+            m.fetchFailed(sres)
+            if sres.TLSInfo != nil {
+                m.checkTLS(sres, &m.stepTLS[i])
+            }
+        default:
+            if sres.TLSInfo != nil {
+                m.checkTLS(sres, &m.stepTLS[i])
+            }
+            if sm.Assert != nil {
+                sm.checkAssertions(sres, body)
+            }
+            if len(sres.Alerts) > 0 && sres.Alerts[len(sres.Alerts) - 1].Kind == AlertAssertion {
+                break                   // there is nothing to capture
+            }
+            if err := step.capture(sres, body, jar, vars); err != nil {
+                sres.alert(AlertAssertion, "%v", err)
+            }
+        }
+
+        failed := sres.Err != nil
+        for _, a := range sres.Alerts {
+            failed = failed || a.Kind == AlertAssertion
+            a.Message = label + ": " + a.Message
+            res.Alerts = append(res.Alerts, a)
+        }
+        if sres.Err != nil {
+            res.Err      = fmt.Errorf("%s: %w", label, sres.Err)
+            res.Category = sres.Category
+        }
+        if failed {
+            completed = false
+            break
+        }
+    }
+    res.Trip = time.Since(res.Start)
+
+    if completed && res.Err == nil {
+        m.checkSize(res)
+        m.checkTime(res)
+        m.checkPhases(res)
+    }
+    return res, nil
+}
+
+// addStep adds the result of a step to that of its scenario: the
+// timings and sizes are totalled, and the status and headers are those
+// of the last step.
+func (r *Result) addStep(step *Result) {
+
+    if len(r.Steps) == 0 {
+        r.FirstDNS = step.FirstDNS
+    }
+    r.Steps     = append(r.Steps, step)
+    r.DNS      += step.DNS
+    r.Connect  += step.Connect
+    r.TLS      += step.TLS
+    r.Write    += step.Write
+    r.Wait     += step.Wait
+    r.TTFB     += step.TTFB
+    r.Transfer += step.Transfer
+    r.Bytes    += step.Bytes
+    r.StatusCode = step.StatusCode
+    r.Status     = step.Status
+    r.ProtoMajor = step.ProtoMajor
+    r.ProtoMinor = step.ProtoMinor
+    r.Header     = step.Header
+    if step.TLSInfo != nil {
+        r.TLSInfo = step.TLSInfo
+    }
+}
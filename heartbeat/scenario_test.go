@@ -0,0 +1,52 @@
+package heartbeat
+
+import (
+    "context"
+    "crypto/tls"
+    "strings"
+    "testing"
+)
+
+// The TLS version negotiated by each step is compared with that of the
+// same step in the previous check, not with that of the step before.
+func TestScenarioTLSVersions(t *testing.T) {
+
+    tls13 := newTLSServer(t, tls.VersionTLS13)
+    tls12 := newTLSServer(t, tls.VersionTLS12)
+
+    m := NewMonitor(tls13.URL)
+    m.TLS.Insecure = true
+    m.Scenario = &Scenario{Steps: []Step{{Name: "new", URL: tls13.URL}, {Name: "old", URL: tls12.URL}}}
+    if err := m.Validate(); err != nil {
+        t.Fatal(err)
+    }
+
+    check := func() []string {
+
+        res, err := m.CheckOnce(context.Background())
+        if err != nil {
+            t.Fatal(err)
+        }
+        if res.Err != nil {
+            t.Fatal(res.Err)
+        }
+        var tlsAlerts []string
+        for _, a := range res.Alerts {
+            if a.Kind == AlertTLS {
+                tlsAlerts = append(tlsAlerts, a.Message)
+            }
+        }
+        return tlsAlerts
+    }
+    for i := 1; i <= 2; i++ {
+        if alerts := check(); len(alerts) > 0 {
+            t.Errorf("check %d: unexpected alerts %q", i, alerts)
+        }
+    }
+
+    m.Scenario.Steps[0].URL = tls12.URL
+    alerts := check()
+    if len(alerts) != 1 || !strings.Contains(alerts[0], "step 1 (new): TLS downgraded from TLS 1.3 to TLS 1.2") {
+        t.Errorf("alerts = %q, want the first step downgraded", alerts)
+    }
+}
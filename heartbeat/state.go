@@ -0,0 +1,190 @@
+package heartbeat
+
+import (
+    "fmt"
+    "time"
+)
+
+// AlertPolicy decides when the problems found by successive fetches are
+// worth an alert, so that a single bad fetch does not raise one and a
+// target that stays down is not reported again on every poll.
+//
+// Each target has a state - OK, WARNING or CRITICAL, or UNKNOWN until it
+// is first known. A target only leaves OK after Failures consecutive
+// failed checks, and only returns to OK (with a single recovery notice)
+// after Successes consecutive good checks.
+//
+// A target is flapping when its checks change status at least
+// FlapChanges times within the last FlapWindow checks. Alerts are then
+// suppressed until the changes fall to half that number.
+type AlertPolicy struct {
+    Failures    int `json:"failures"`       // consecutive failed checks before alerting (0 = 1)
+    Successes   int `json:"successes"`      // consecutive good checks before recovery (0 = 1)
+    FlapWindow  int `json:"flap_window"`    // number of recent checks examined (0 = no flap detection)
+    FlapChanges int `json:"flap_changes"`   // status changes within the window that mean flapping
+}
+
+// Validate checks that the policy is usable.
+func (p *AlertPolicy) Validate() error {
+
+    if p.Failures < 0 || p.Successes < 0 || p.FlapWindow < 0 || p.FlapChanges < 0 {
+        return fmt.Errorf("alerting settings must not be negative")
+    }
+    if p.FlapWindow > 0 && (p.FlapChanges < 1 || p.FlapChanges >= p.FlapWindow) {
+        return fmt.Errorf("flap changes must be between 1 and %d (one less than the flap window)", p.FlapWindow - 1)
+    }
+    if p.FlapWindow == 0 && p.FlapChanges > 0 {
+        return fmt.Errorf("flap changes needs a flap window")
+    }
+    return nil
+}
+
+// targetState follows the state of a Monitor's target from check to check.
+type targetState struct {
+    state    Status
+    checks   int                   // checks so far
+    streak   int                   // consecutive checks disagreeing with state
+    failing  int                   // consecutive failed checks
+    since    time.Time             // when the current problem began
+    failed   int                   // failed checks since then
+    alerted  map[AlertKind]bool    // kinds already alerted for the current problem
+    history  []Status              // status of recent checks, for flap detection
+    flapping bool
+}
+
+// State returns the current state of the target: UNKNOWN until the
+// first check is run by Run.
+func (m *Monitor) State() Status {
+
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if m.status.checks == 0 {
+        return StatusUnknown
+    }
+    return m.status.state
+}
+
+// track applies the AlertPolicy to the result of a check, updating the
+// state of the target. Alerts (and notices) that should not be reported
+// are moved to the Result's Suppressed list.
+func (m *Monitor) track(res *Result) {
+
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    p := m.Policy
+    s := &m.status
+    status, _ := m.thresholds().Evaluate(res, nil)
+
+    if s.checks == 0 {
+        s.state = StatusUnknown
+    }
+    s.checks++
+    if status == StatusOK {
+        s.failing = 0
+    } else {
+        s.failing++
+    }
+
+    stopped := -1                       // changes, if flapping has stopped
+    if p.FlapWindow > 0 {
+        s.history = append(s.history, status)
+        if len(s.history) > p.FlapWindow {
+            s.history = append(s.history[:0], s.history[len(s.history) - p.FlapWindow:]...)
+        }
+        changes := 0
+        for i := 1; i < len(s.history); i++ {
+            if s.history[i] != s.history[i - 1] {
+                changes++
+            }
+        }
+        switch {
+        case !s.flapping && changes >= p.FlapChanges:
+            s.flapping = true
+            res.notice(AlertFlapping, "FLAPPING status changed %d times in the last %d checks, alerts suppressed",
+                       changes, len(s.history))
+        case s.flapping && changes <= p.FlapChanges / 2:
+            s.flapping, stopped = false, changes
+        }
+    }
+
+    failures, successes := p.Failures, p.Successes
+    if failures < 1 {
+        failures = 1
+    }
+    if successes < 1 {
+        successes = 1
+    }
+
+    problem := s.state == StatusWarning || s.state == StatusCritical
+    switch {
+    case status == StatusOK && s.state == StatusUnknown:
+        s.state, s.streak = StatusOK, 0
+
+    case status == StatusOK && problem:
+        s.streak++
+        if s.streak >= successes {
+            res.notice(AlertRecovered, "RECOVERED after %d failed checks (%s since %s)",
+                       s.failed, s.state, s.since.Format(time.RFC3339))
+            s.state, s.streak, s.alerted = StatusOK, 0, nil
+        }
+
+    case status == StatusOK:
+        s.streak = 0
+
+    case problem:
+        s.state, s.streak = status, 0  // escalate or de-escalate at once
+        s.failed++
+
+    default:                            // a failure while OK (or UNKNOWN)
+        if s.streak == 0 {
+            s.since, s.failed = res.Start, 0
+        }
+        s.streak++
+        s.failed++
+        if s.streak >= failures {
+            s.state, s.streak = status, 0
+        }
+    }
+    res.State    = s.state
+    res.Flapping = s.flapping
+    res.Failing  = s.failing
+    if stopped >= 0 {
+        res.notice(AlertFlapping, "STOPPED FLAPPING status changed %d times in the last %d checks, now %s",
+                   stopped, len(s.history), s.state)
+    }
+
+    // Only alert once the problem is confirmed, then only once for each
+    // kind (although one check may raise several alerts of the same kind).
+    var alerts []Alert
+    for _, a := range res.Alerts {
+        if s.flapping || (s.state != StatusWarning && s.state != StatusCritical) || s.alerted[a.Kind] {
+            res.Suppressed = append(res.Suppressed, a)
+            continue
+        }
+        alerts = append(alerts, a)
+    }
+    for _, a := range alerts {
+        if s.alerted == nil {
+            s.alerted = map[AlertKind]bool{}
+        }
+        s.alerted[a.Kind] = true
+    }
+    res.Alerts = alerts
+
+    if s.flapping {
+        var notices []Alert
+        for _, n := range res.Notices {
+            if n.Kind == AlertFlapping {
+                notices = append(notices, n)
+            } else {
+                res.Suppressed = append(res.Suppressed, n)
+            }
+        }
+        res.Notices = notices
+    }
+
+    for _, a := range res.Suppressed {
+        m.debugf("suppressed (%s, %s): %s\n", s.state, a.Kind, a.Message)
+    }
+}
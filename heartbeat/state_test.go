@@ -0,0 +1,166 @@
+package heartbeat
+
+import (
+    "errors"
+    "strings"
+    "testing"
+    "time"
+)
+
+// testCheck returns the result of a check: '.' for one that is OK, 'w'
+// for one that raised a (WARNING) time alert and 'c' for a (CRITICAL)
+// failed fetch.
+func testCheck(c byte, start time.Time) *Result {
+
+    res := &Result{Name: "test", URL: "http://localhost/", Start: start}
+    switch c {
+    case 'w':
+        res.alert(AlertTime, "slow")
+    case 'c':
+        res.Err, res.Category = errors.New("connection refused"), ErrorRefused
+        res.failed(AlertUnreachable, "unreachable")
+    }
+    return res
+}
+
+func TestTrack(t *testing.T) {
+
+    for _, tc := range []struct {
+        name     string
+        policy   AlertPolicy
+        checks   string        // see testCheck
+        states   string        // first letter of the state after each check
+        flapping string        // 'F' while flapping
+        events   []string      // kinds of the alerts and notices reported by each check
+    }{
+        {
+            name:   "alert at once",
+            checks: "..c.c",
+            states: "OOCOC",
+            events: []string{"", "", "unreachable", "recovered", "unreachable"},
+        },
+        {
+            name:   "debounce failures and successes",
+            policy: AlertPolicy{Failures: 3, Successes: 2},
+            checks: "cc.ccc..c..",
+            states: "UUOOOCCOOOO",
+            events: []string{"", "", "", "", "", "unreachable", "", "recovered", "", "", ""},
+        },
+        {
+            name:   "recovery interrupted",
+            policy: AlertPolicy{Successes: 2},
+            checks: "c.c..",
+            states: "CCCCO",
+            events: []string{"unreachable", "", "", "", "recovered"},
+        },
+        {
+            name:   "escalate, alerting once for each kind",
+            policy: AlertPolicy{Failures: 2},
+            checks: "wwwccw.",
+            states: "UWWCCWO",
+            events: []string{"", "time", "", "unreachable", "", "", "recovered"},
+        },
+        {
+            name:     "flapping",
+            policy:   AlertPolicy{FlapWindow: 6, FlapChanges: 4},
+            checks:   "c.c.c.....",
+            states:   "COCOCOOOOO",
+            flapping: "....FFFF..",
+            events:   []string{"unreachable", "recovered", "unreachable", "recovered", "flapping", "", "", "", "flapping", ""},
+        },
+        {
+            name:     "flapping while failing",
+            policy:   AlertPolicy{Failures: 2, FlapWindow: 4, FlapChanges: 2},
+            checks:   "c.c.cccc",
+            states:   "UOOOOCCC",
+            flapping: "..FFFF..",
+            // the alert suppressed while flapping is sent when it stops
+            events:   []string{"", "", "flapping", "", "", "", "unreachable,flapping", ""},
+        },
+    } {
+        m := NewMonitor("http://localhost/")
+        m.Policy = tc.policy
+        if s := m.State(); s != StatusUnknown {
+            t.Errorf("%s: state before checking = %s", tc.name, s)
+        }
+        if tc.flapping == "" {
+            tc.flapping = strings.Repeat(".", len(tc.checks))
+        }
+
+        start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+        for i := 0; i < len(tc.checks); i++ {
+            res := testCheck(tc.checks[i], start.Add(time.Duration(i) * time.Minute))
+            raised := len(res.Alerts)
+            m.track(res)
+
+            var kinds []string
+            for _, a := range append(res.Alerts, res.Notices...) {
+                kinds = append(kinds, string(a.Kind))
+            }
+            events := strings.Join(kinds, ",")
+            if state := res.State.String()[:1]; state != tc.states[i:i + 1] || m.State() != res.State {
+                t.Errorf("%s: check %d: state %s (monitor %s), want %c", tc.name, i + 1, res.State, m.State(), tc.states[i])
+            }
+            if res.Flapping != (tc.flapping[i] == 'F') {
+                t.Errorf("%s: check %d: flapping %v", tc.name, i + 1, res.Flapping)
+            }
+            if events != tc.events[i] {
+                t.Errorf("%s: check %d: reported %q, want %q", tc.name, i + 1, events, tc.events[i])
+            }
+            if len(res.Alerts) + len(res.Suppressed) < raised {
+                t.Errorf("%s: check %d: %d alerts lost", tc.name, i + 1, raised - len(res.Alerts) - len(res.Suppressed))
+            }
+        }
+    }
+}
+
+func TestTrackFailing(t *testing.T) {
+
+    m := NewMonitor("http://localhost/")
+    m.Policy = AlertPolicy{Failures: 3}
+    want := []int{1, 2, 0, 1, 0}
+    for i, c := range []byte("cc.w.") {
+        res := testCheck(c, time.Now())
+        m.track(res)
+        if res.Failing != want[i] {
+            t.Errorf("check %d: failing %d, want %d", i + 1, res.Failing, want[i])
+        }
+    }
+}
+
+func TestRecoveredSince(t *testing.T) {
+
+    m := NewMonitor("http://localhost/")
+    m.Policy = AlertPolicy{Failures: 2}
+    start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+    var res *Result
+    for i, c := range []byte(".ccc.") {
+        res = testCheck(c, start.Add(time.Duration(i) * time.Minute))
+        m.track(res)
+    }
+    if len(res.Notices) != 1 || !strings.Contains(res.Notices[0].Message, "after 3 failed checks (CRITICAL since 2024-03-01T12:01:00Z)") {
+        t.Errorf("notices = %+v", res.Notices)
+    }
+}
+
+func TestAlertPolicyValidate(t *testing.T) {
+
+    for _, tc := range []struct {
+        p   AlertPolicy
+        err string
+    }{
+        {AlertPolicy{},                                               ""},
+        {AlertPolicy{Failures: 3, Successes: 2},                      ""},
+        {AlertPolicy{FlapWindow: 10, FlapChanges: 4},                 ""},
+        {AlertPolicy{FlapWindow: 10, FlapChanges: 9},                 ""},
+        {AlertPolicy{Failures: -1},                                   "must not be negative"},
+        {AlertPolicy{FlapWindow: 10},                                 "between 1 and 9"},
+        {AlertPolicy{FlapWindow: 10, FlapChanges: 10},                "between 1 and 9"},
+        {AlertPolicy{FlapChanges: 3},                                 "needs a flap window"},
+    } {
+        err := tc.p.Validate()
+        if tc.err == "" && err != nil || tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+            t.Errorf("%+v: error = %v, want %q", tc.p, err, tc.err)
+        }
+    }
+}
@@ -0,0 +1,208 @@
+package heartbeat
+
+import (
+    "bufio"
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// DefaultStoreDir is where the history command looks for results when
+// no store is specified.
+const DefaultStoreDir = "heartbeat-history"
+
+const segmentLayout = "2006-01-02"  // one segment file per (UTC) day
+
+// Record is the stored form of a Result.
+type Record struct {
+    Time       time.Time          `json:"time"`
+    Target     string             `json:"target"`
+    URL        string             `json:"url"`
+    StatusCode int                `json:"status_code,omitempty"`
+    Bytes      int64              `json:"bytes"`
+    Phases     map[string]float64 `json:"phases_ms"`          // by the names of Result.Phases
+    Category   ErrorCategory      `json:"error,omitempty"`
+    State      string             `json:"state"`
+    Alerts     []AlertKind        `json:"alerts,omitempty"`    // those reported, not suppressed
+    Redirects  []string           `json:"redirects,omitempty"` // the URLs redirected to, in order
+}
+
+// NewRecord returns the Record of a Result.
+func NewRecord(res *Result) Record {
+
+    r := Record{
+        Time:       res.Start,
+        Target:     res.Name,
+        URL:        res.URL,
+        StatusCode: res.StatusCode,
+        Bytes:      res.Bytes,
+        Phases:     map[string]float64{},
+        Category:   res.Category,
+        State:      res.State.String(),
+        Redirects:  res.Chain(),
+    }
+    for _, p := range res.Phases() {
+        r.Phases[p.Name] = float64(p.Duration) / float64(time.Millisecond)
+    }
+    for _, a := range res.Alerts {
+        r.Alerts = append(r.Alerts, a.Kind)
+    }
+    return r
+}
+
+// Store keeps the results of checks in a directory of append-only
+// segment files, one per day, holding a Record per line as JSON.
+// Segments older than the retention period are removed as new ones
+// are started.
+//
+// A Store may be shared by any number of Monitors.
+type Store struct {
+    Dir       string
+    Retention time.Duration     // 0 = keep everything
+
+    mu        sync.Mutex
+    file      *os.File          // the current segment
+    day       string
+}
+
+// OpenStore returns a Store using the directory, creating it if need be.
+func OpenStore(dir string, retention time.Duration) (*Store, error) {
+
+    if retention < 0 {
+        return nil, fmt.Errorf("store retention must not be negative")
+    }
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return nil, fmt.Errorf("unable to create store: %v", err)
+    }
+    return &Store{Dir: dir, Retention: retention}, nil
+}
+
+// Append adds the result of a check to the store.
+func (s *Store) Append(res *Result) error {
+
+    line, err := json.Marshal(NewRecord(res))
+    if err != nil {
+        return err
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    day := res.Start.UTC().Format(segmentLayout)
+    if s.file == nil || day != s.day {
+        if s.file != nil {
+            s.file.Close()
+            s.file = nil
+        }
+        f, err := os.OpenFile(filepath.Join(s.Dir, day + ".jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+        if err != nil {
+            return err
+        }
+        s.file, s.day = f, day
+        s.prune(res.Start)
+    }
+    _, err = s.file.Write(append(line, '\n'))
+    return err
+}
+
+// Close closes the current segment.
+func (s *Store) Close() error {
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.file == nil {
+        return nil
+    }
+    err := s.file.Close()
+    s.file = nil
+    return err
+}
+
+// Query returns the records of a target (by name or URL, or all targets
+// if empty) from the period between since and until, oldest first. A
+// zero until means up to now.
+func (s *Store) Query(target string, since, until time.Time) ([]Record, error) {
+
+    days, err := s.segments()
+    if err != nil {
+        return nil, err
+    }
+    var records []Record
+    for _, day := range days {
+        start, _ := time.Parse(segmentLayout, day)
+        if start.Add(24 * time.Hour).Before(since) || (!until.IsZero() && start.After(until)) {
+            continue
+        }
+        name := filepath.Join(s.Dir, day + ".jsonl")
+        f, err := os.Open(name)
+        if err != nil {
+            return nil, err
+        }
+        scanner := bufio.NewScanner(f)
+        scanner.Buffer(nil, 1024 * 1024)
+        for n := 1; scanner.Scan(); n++ {
+            var r Record
+            if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+                f.Close()
+                return nil, fmt.Errorf("%s:%d: %v", name, n, err)
+            }
+            if target != "" && r.Target != target && r.URL != target {
+                continue
+            }
+            if r.Time.Before(since) || (!until.IsZero() && r.Time.After(until)) {
+                continue
+            }
+            records = append(records, r)
+        }
+        err = scanner.Err()
+        f.Close()
+        if err != nil {
+            return nil, fmt.Errorf("%s: %v", name, err)
+        }
+    }
+    sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+    return records, nil
+}
+
+// segments returns the days held by the store, oldest first.
+func (s *Store) segments() ([]string, error) {
+
+    files, err := ioutil.ReadDir(s.Dir)
+    if err != nil {
+        return nil, err
+    }
+    var days []string
+    for _, fi := range files {
+        day := strings.TrimSuffix(fi.Name(), ".jsonl")
+        if _, err := time.Parse(segmentLayout, day); err == nil && day != fi.Name() {
+            days = append(days, day)
+        }
+    }
+    sort.Strings(days)
+    return days, nil
+}
+
+// prune removes the segments that hold nothing newer than the retention
+// period.
+func (s *Store) prune(now time.Time) {
+
+    if s.Retention == 0 {
+        return
+    }
+    days, err := s.segments()
+    if err != nil {
+        return
+    }
+    for _, day := range days {
+        start, _ := time.Parse(segmentLayout, day)
+        if start.Add(24 * time.Hour).Before(now.Add(-s.Retention)) {
+            os.Remove(filepath.Join(s.Dir, day + ".jsonl"))
+        }
+    }
+}
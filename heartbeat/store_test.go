@@ -0,0 +1,131 @@
+package heartbeat
+
+import (
+    "io/ioutil"
+    "testing"
+    "time"
+)
+
+func storeResult(name string, start time.Time) *Result {
+
+    return &Result{Name: name, URL: "http://" + name + "/", Start: start, StatusCode: 200, Trip: 25 * time.Millisecond}
+}
+
+func storeDays(t *testing.T, s *Store) []string {
+
+    days, err := s.segments()
+    if err != nil {
+        t.Fatal(err)
+    }
+    return days
+}
+
+// Results either side of midnight (UTC) go to the segments of their days,
+// and are read back from both.
+func TestStoreDayBoundary(t *testing.T) {
+
+    s, err := OpenStore(t.TempDir(), 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer s.Close()
+
+    midnight := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+    for _, res := range []*Result{
+        storeResult("a", midnight.Add(-2 * time.Minute)),
+        storeResult("b", midnight.Add(-time.Minute)),
+        storeResult("a", midnight.Add(time.Minute)),
+        storeResult("b", midnight.Add(2 * time.Minute)),
+    } {
+        if err := s.Append(res); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if days := storeDays(t, s); len(days) != 2 || days[0] != "2024-03-09" || days[1] != "2024-03-10" {
+        t.Errorf("segments %q", days)
+    }
+
+    for _, tc := range []struct {
+        target string
+        since  time.Duration    // from midnight
+        until  time.Duration
+        want   int
+    }{
+        {"",            -time.Hour,         0,                  4},
+        {"a",           -time.Hour,         time.Hour,          2},
+        {"http://b/",   -time.Hour,         time.Hour,          2},
+        {"",            -90 * time.Second,  90 * time.Second,   2},
+        {"",            0,                  time.Hour,          2},
+        {"a",           time.Hour,          0,                  0},
+        {"c",           -time.Hour,         0,                  0},
+    } {
+        var until time.Time
+        if tc.until != 0 {
+            until = midnight.Add(tc.until)
+        }
+        records, err := s.Query(tc.target, midnight.Add(tc.since), until)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if len(records) != tc.want {
+            t.Errorf("Query(%q, %v, %v) returned %d records, want %d", tc.target, tc.since, tc.until, len(records), tc.want)
+        }
+        for i := 1; i < len(records); i++ {
+            if records[i].Time.Before(records[i-1].Time) {
+                t.Errorf("Query(%q) records out of order", tc.target)
+            }
+        }
+    }
+
+    records, _ := s.Query("a", time.Time{}, time.Time{})
+    if len(records) != 2 || !records[1].Time.Equal(midnight.Add(time.Minute)) || records[1].Phases["total"] != 25 || records[1].StatusCode != 200 {
+        t.Errorf("records %+v", records)
+    }
+}
+
+// Starting a segment removes those holding nothing within the retention
+// period.
+func TestStoreRetention(t *testing.T) {
+
+    s, err := OpenStore(t.TempDir(), 48 * time.Hour)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer s.Close()
+
+    day := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+    for _, d := range []int{0, 1, 2, 3} {
+        if err := s.Append(storeResult("a", day.AddDate(0, 0, d))); err != nil {
+            t.Fatal(err)
+        }
+    }
+    // The 10th ended more than 48 hours before noon on the 13th.
+    if days := storeDays(t, s); len(days) != 3 || days[0] != "2024-03-11" {
+        t.Errorf("segments %q, want the 11th to the 13th", days)
+    }
+
+    if err := s.Append(storeResult("a", day.AddDate(0, 0, 10))); err != nil {
+        t.Fatal(err)
+    }
+    if days := storeDays(t, s); len(days) != 1 || days[0] != "2024-03-20" {
+        t.Errorf("segments %q, want the 20th alone", days)
+    }
+}
+
+func TestOpenStore(t *testing.T) {
+
+    if _, err := OpenStore(t.TempDir(), -time.Hour); err == nil {
+        t.Error("a negative retention was accepted")
+    }
+    dir := t.TempDir()
+    if err := ioutil.WriteFile(dir + "/notes.txt", []byte("not a segment"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    s, err := OpenStore(dir, 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if days := storeDays(t, s); len(days) != 0 {
+        t.Errorf("segments %q", days)
+    }
+}
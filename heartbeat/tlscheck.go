@@ -0,0 +1,365 @@
+package heartbeat
+
+import (
+    "crypto/sha256"
+    "crypto/tls"
+    "crypto/x509"
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "net/url"
+    "strings"
+    "time"
+)
+
+// DefaultExpiryDays is how many days before a certificate expires that
+// an alert is raised, by default.
+const DefaultExpiryDays = 14
+
+// TLSPolicy decides how HTTPS targets are connected to, and what is
+// expected of the connection. Every certificate in the chain must be
+// valid for at least ExpiryDays more days, and the server's certificate
+// must match the target's host name (or ServerName). A TLS version below
+// MinVersion - or below the version negotiated by the previous fetch -
+// is reported as a downgrade.
+//
+// Certificates are verified against the system's CAs and those in
+// CAFiles; Cert and Key (PEM files) are presented to servers that ask
+// for a client certificate. If there are Pins, one of them must match a
+// certificate in the chain: either its public key, as in
+// "sha256/<base64 of the SHA-256 of the SubjectPublicKeyInfo>" (as with
+// HPKP and curl's --pinnedpubkey), or the certificate itself, as in
+// "sha256:<hex SHA-256 fingerprint>" (colons are allowed). A server
+// matching none of them is hung up on during the handshake, before any
+// request (or credentials) can be sent, and the fetch fails. Insecure
+// skips verification altogether, for test servers with self-signed
+// certificates - pinning their certificate is then the only check made.
+type TLSPolicy struct {
+    ExpiryDays int      `json:"expiry_days"`  // 0 = DefaultExpiryDays
+    MinVersion string   `json:"min_version"`  // "1.0", "1.1", "1.2" or "1.3" (default any)
+    CAFiles    []string `json:"ca_files"`     // trusted as well as the system's CAs
+    Cert       string   `json:"cert"`         // client certificate, for mutual TLS
+    Key        string   `json:"key"`          // its private key (default in Cert)
+    ServerName string   `json:"server_name"`  // sent (SNI) and verified instead of the URL's host
+    Pins       []string `json:"pins"`
+    Insecure   bool     `json:"insecure"`     // do not verify certificates
+
+    minVersion uint16
+    pins       []certPin
+    config     *tls.Config          // nil for the default
+}
+
+// certPin is a parsed pin.
+type certPin struct {
+    spki bool                       // of the public key, rather than the certificate
+    hash [sha256.Size]byte
+}
+
+// TLSInfo describes the TLS connection of a fetch (of its final
+// response, if it was redirected).
+type TLSInfo struct {
+    Version     string         // as in "TLS 1.3"
+    CipherSuite string
+    ServerName  string         // the name asked for
+    Certs       []CertInfo     // the server's chain, its own certificate first
+    Verified    bool           // the chain was verified
+
+    version     uint16
+}
+
+// CertInfo describes a certificate.
+type CertInfo struct {
+    Subject   string
+    Issuer    string
+    DNSNames  []string
+    IPs       []string
+    NotBefore time.Time
+    NotAfter  time.Time
+
+    cert      *x509.Certificate
+}
+
+var tlsVersions = map[string]uint16{
+    "1.0": tls.VersionTLS10,
+    "1.1": tls.VersionTLS11,
+    "1.2": tls.VersionTLS12,
+    "1.3": tls.VersionTLS13,
+}
+
+// Validate checks that the TLS settings are usable.
+func (p *TLSPolicy) Validate() error {
+
+    if p.ExpiryDays < 0 {
+        return fmt.Errorf("certificate expiry days must not be negative")
+    }
+    p.minVersion = 0
+    if p.MinVersion != "" {
+        v, ok := tlsVersions[strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(p.MinVersion)), "TLS")]
+        if !ok {
+            return fmt.Errorf("unknown TLS version '%s' (use 1.0, 1.1, 1.2 or 1.3)", p.MinVersion)
+        }
+        p.minVersion = v
+    }
+    if p.Key != "" && p.Cert == "" {
+        return fmt.Errorf("client key needs a client certificate")
+    }
+
+    p.pins = nil
+    for _, pin := range p.Pins {
+        cp, err := parsePin(pin)
+        if err != nil {
+            return err
+        }
+        p.pins = append(p.pins, cp)
+    }
+
+    p.config = nil
+    if len(p.CAFiles) == 0 && p.Cert == "" && p.ServerName == "" && !p.Insecure && len(p.pins) == 0 {
+        return nil
+    }
+    config := &tls.Config{ServerName: p.ServerName, InsecureSkipVerify: p.Insecure}
+    if len(p.pins) > 0 {
+        pins := p.pins
+        config.VerifyConnection = func(cs tls.ConnectionState) error {
+            return verifyPins(pins, cs)
+        }
+    }
+    if len(p.CAFiles) > 0 {
+        pool, err := x509.SystemCertPool()
+        if err != nil {
+            pool = x509.NewCertPool()
+        }
+        for _, file := range p.CAFiles {
+            pem, err := ioutil.ReadFile(file)
+            if err != nil {
+                return fmt.Errorf("unable to read CA file: %v", err)
+            }
+            if !pool.AppendCertsFromPEM(pem) {
+                return fmt.Errorf("no certificates found in CA file '%s'", file)
+            }
+        }
+        config.RootCAs = pool
+    }
+    if p.Cert != "" {
+        key := p.Key
+        if key == "" {
+            key = p.Cert
+        }
+        cert, err := tls.LoadX509KeyPair(p.Cert, key)
+        if err != nil {
+            return fmt.Errorf("unable to load client certificate: %v", err)
+        }
+        config.Certificates = []tls.Certificate{cert}
+    }
+    p.config = config
+    return nil
+}
+
+// parsePin parses a pin, as in "sha256/<base64>" or "sha256:<hex>".
+func parsePin(pin string) (certPin, error) {
+
+    var cp certPin
+    var hash []byte
+    var err error
+    switch {
+    case strings.HasPrefix(pin, "sha256/"):
+        cp.spki = true
+        hash, err = base64.StdEncoding.DecodeString(strings.TrimLeft(pin[len("sha256/"):], "/"))
+    case strings.HasPrefix(pin, "sha256:"):
+        hash, err = hex.DecodeString(strings.Replace(pin[len("sha256:"):], ":", "", -1))
+    default:
+        return cp, fmt.Errorf("invalid pin '%s' (use sha256/<base64 public key hash> or sha256:<hex fingerprint>)", pin)
+    }
+    if err != nil || len(hash) != sha256.Size {
+        return cp, fmt.Errorf("invalid pin '%s' (expected a SHA-256 hash)", pin)
+    }
+    copy(cp.hash[:], hash)
+    return cp, nil
+}
+
+// clientConfig returns the TLS configuration to connect with (or nil
+// for the default), validating the policy if that has not been done.
+func (p *TLSPolicy) clientConfig() (*tls.Config, error) {
+
+    if p.config == nil {
+        if err := p.Validate(); err != nil {
+            return nil, err
+        }
+    }
+    return p.config, nil
+}
+
+// spkiPin returns the public key pin of a certificate.
+func spkiPin(c *x509.Certificate) string {
+
+    hash := sha256.Sum256(c.RawSubjectPublicKeyInfo)
+    return "sha256/" + base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// PinError reports that none of the certificates presented by a server
+// matched the TLSPolicy's pins.
+type PinError struct {
+    Key   string                // the pin of the server's own public key
+    chain []*x509.Certificate
+}
+
+func (e *PinError) Error() string {
+
+    return "certificate pin mismatch: the server's key is " + e.Key
+}
+
+// verifyPins checks that a certificate in the connection's verified chain
+// (or if it was not verified, one sent by the server) matches a pin.
+func verifyPins(pins []certPin, cs tls.ConnectionState) error {
+
+    chains := cs.VerifiedChains
+    if len(chains) == 0 {
+        chains = [][]*x509.Certificate{cs.PeerCertificates}
+    }
+    for _, chain := range chains {
+        for _, c := range chain {
+            spki := sha256.Sum256(c.RawSubjectPublicKeyInfo)
+            raw  := sha256.Sum256(c.Raw)
+            for _, pin := range pins {
+                if (pin.spki && pin.hash == spki) || (!pin.spki && pin.hash == raw) {
+                    return nil
+                }
+            }
+        }
+    }
+    e := &PinError{chain: cs.PeerCertificates}
+    if len(cs.PeerCertificates) > 0 {
+        e.Key = spkiPin(cs.PeerCertificates[0])
+    }
+    return e
+}
+
+func (p *TLSPolicy) expiryDays() int {
+
+    if p.ExpiryDays > 0 {
+        return p.ExpiryDays
+    }
+    return DefaultExpiryDays
+}
+
+// newTLSInfo describes a TLS connection.
+func newTLSInfo(state *tls.ConnectionState) *TLSInfo {
+
+    info := &TLSInfo{
+        Version:     tls.VersionName(state.Version),
+        CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+        ServerName:  state.ServerName,
+        Verified:    len(state.VerifiedChains) > 0,
+        version:     state.Version,
+    }
+    chain := state.PeerCertificates
+    if info.Verified {
+        chain = state.VerifiedChains[0]     // as far as the root
+    }
+    info.Certs = certInfos(chain)
+    return info
+}
+
+// tlsErrorInfo describes the certificates that failed verification (or
+// did not match the pins), if that is why a fetch failed.
+func tlsErrorInfo(err error) *TLSInfo {
+
+    var verification *tls.CertificateVerificationError
+    if errors.As(err, &verification) {
+        return &TLSInfo{Certs: certInfos(verification.UnverifiedCertificates)}
+    }
+    var hostname x509.HostnameError
+    if errors.As(err, &hostname) && hostname.Certificate != nil {
+        return &TLSInfo{Certs: certInfos([]*x509.Certificate{hostname.Certificate})}
+    }
+    var pin *PinError
+    if errors.As(err, &pin) {
+        return &TLSInfo{Certs: certInfos(pin.chain)}
+    }
+    return nil
+}
+
+func certInfos(chain []*x509.Certificate) []CertInfo {
+
+    var certs []CertInfo
+    for _, c := range chain {
+        ci := CertInfo{
+            Subject:   c.Subject.String(),
+            Issuer:    c.Issuer.String(),
+            DNSNames:  c.DNSNames,
+            NotBefore: c.NotBefore,
+            NotAfter:  c.NotAfter,
+            cert:      c,
+        }
+        for _, ip := range c.IPAddresses {
+            ci.IPs = append(ci.IPs, ip.String())
+        }
+        certs = append(certs, ci)
+    }
+    return certs
+}
+
+// Expires returns when the first certificate in the chain expires (or
+// the zero time if there are none).
+func (info *TLSInfo) Expires() time.Time {
+
+    var first time.Time
+    for _, c := range info.Certs {
+        if first.IsZero() || c.NotAfter.Before(first) {
+            first = c.NotAfter
+        }
+    }
+    return first
+}
+
+// checkTLS compares the TLS connection with the Monitor's TLSPolicy,
+// and with the version negotiated by the previous fetch of the same
+// request (*prev, which is then updated). If the handshake failed only
+// the certificates (that failed verification) are checked.
+func (m *Monitor) checkTLS(res *Result, prev *uint16) {
+
+    info := res.TLSInfo
+    host := m.TLS.ServerName
+    if n := len(res.Hops); n > 0 && host == "" {
+        if u, err := url.Parse(res.Hops[n - 1].URL); err == nil {
+            host = u.Hostname()
+        }
+    }
+    for i, c := range info.Certs {
+        m.debugf("certificate %d: %s (issuer %s) DNS %v IP %v, valid %s to %s\n", i, c.Subject, c.Issuer,
+                 c.DNSNames, c.IPs, c.NotBefore.Format("2006-01-02"), c.NotAfter.Format("2006-01-02"))
+    }
+
+    days := m.TLS.expiryDays()
+    for _, c := range info.Certs {
+        left := c.NotAfter.Sub(res.Start)
+        switch {
+        case left <= 0:
+            res.alert(AlertTLS, "certificate '%s' expired on %s",
+                      c.Subject, c.NotAfter.Format("2006-01-02"))
+        case left < time.Duration(days) * 24 * time.Hour:
+            res.alert(AlertTLS, "certificate '%s' expires on %s (in %d days)",
+                      c.Subject, c.NotAfter.Format("2006-01-02"), int(left.Hours() / 24))
+        }
+    }
+
+    if info.version == 0 {
+        return                          // the handshake failed, as fetchFailed has reported
+    }
+    if len(info.Certs) > 0 && host != "" && !m.TLS.Insecure {
+        if err := info.Certs[0].cert.VerifyHostname(host); err != nil {
+            res.alert(AlertTLS, "hostname mismatch: %v", err)
+        }
+    }
+    switch {
+    case m.TLS.minVersion != 0 && info.version < m.TLS.minVersion:
+        res.alert(AlertTLS, "negotiated %s, expected at least %s",
+                  info.Version, tls.VersionName(m.TLS.minVersion))
+    case *prev != 0 && info.version < *prev:
+        res.alert(AlertTLS, "TLS downgraded from %s to %s",
+                  tls.VersionName(*prev), info.Version)
+    }
+    *prev = info.version
+}
@@ -0,0 +1,257 @@
+package heartbeat
+
+import (
+    "context"
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/rand"
+    "crypto/sha256"
+    "crypto/tls"
+    "crypto/x509"
+    "crypto/x509/pkix"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/pem"
+    "io/ioutil"
+    "log"
+    "math/big"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+// testCA issues the certificates of test servers.
+type testCA struct {
+    cert *x509.Certificate
+    key  *ecdsa.PrivateKey
+    file string             // the CA certificate, for TLSPolicy.CAFiles
+}
+
+func newTestCA(t *testing.T) *testCA {
+
+    key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    if err != nil {
+        t.Fatal(err)
+    }
+    tmpl := &x509.Certificate{
+        SerialNumber:          big.NewInt(1),
+        Subject:               pkix.Name{CommonName: "heartbeat test CA"},
+        NotBefore:             time.Now().Add(-time.Hour),
+        NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+        IsCA:                  true,
+        KeyUsage:              x509.KeyUsageCertSign,
+        BasicConstraintsValid: true,
+    }
+    der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+    if err != nil {
+        t.Fatal(err)
+    }
+    ca := &testCA{key: key, file: filepath.Join(t.TempDir(), "ca.pem")}
+    if ca.cert, err = x509.ParseCertificate(der); err != nil {
+        t.Fatal(err)
+    }
+    if err := ioutil.WriteFile(ca.file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+        t.Fatal(err)
+    }
+    return ca
+}
+
+// issue returns a certificate for the host (a name or an IP address),
+// valid until notAfter.
+func (ca *testCA) issue(t *testing.T, host string, notAfter time.Time) tls.Certificate {
+
+    key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    if err != nil {
+        t.Fatal(err)
+    }
+    tmpl := &x509.Certificate{
+        SerialNumber: big.NewInt(time.Now().UnixNano()),
+        Subject:      pkix.Name{CommonName: host},
+        NotBefore:    time.Now().Add(-72 * time.Hour),
+        NotAfter:     notAfter,
+        KeyUsage:     x509.KeyUsageDigitalSignature,
+        ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+    }
+    if ip := net.ParseIP(host); ip != nil {
+        tmpl.IPAddresses = []net.IP{ip}
+    } else {
+        tmpl.DNSNames = []string{host}
+    }
+    der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// newCertServer starts a TLS server with the certificate, counting the
+// requests it receives.
+func newCertServer(t *testing.T, cert tls.Certificate, requests *int) *httptest.Server {
+
+    srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+        *requests++
+        w.Write([]byte("ok"))
+    }))
+    srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+    srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)    // failed handshakes
+    srv.StartTLS()
+    t.Cleanup(srv.Close)
+    return srv
+}
+
+// newTLSServer starts a TLS server (with httptest's certificate) that
+// negotiates at most the given version.
+func newTLSServer(t *testing.T, max uint16) *httptest.Server {
+
+    srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+        w.Write([]byte("ok"))
+    }))
+    srv.TLS = &tls.Config{MaxVersion: max}
+    srv.StartTLS()
+    t.Cleanup(srv.Close)
+    return srv
+}
+
+// checkTLSAlerts checks the Monitor once, returning the messages of the
+// TLS alerts raised.
+func checkTLSAlerts(t *testing.T, m *Monitor) (*Result, []string) {
+
+    res, err := m.CheckOnce(context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+    var alerts []string
+    for _, a := range res.Alerts {
+        if a.Kind == AlertTLS {
+            alerts = append(alerts, a.Message)
+        }
+    }
+    return res, alerts
+}
+
+func TestCheckTLSCertificates(t *testing.T) {
+
+    ca := newTestCA(t)
+    for _, tc := range []struct {
+        name     string
+        host     string             // of the certificate
+        notAfter time.Duration      // from now
+        failed   bool
+        alerts   []string
+    }{
+        {"valid",           "127.0.0.1",     90 * 24 * time.Hour, false, nil},
+        {"expiring",        "127.0.0.1",     5 * 24 * time.Hour,  false, []string{"certificate 'CN=127.0.0.1' expires on"}},
+        {"expired",         "127.0.0.1",     -24 * time.Hour,     true,  []string{"certificate 'CN=127.0.0.1' expired on"}},
+        {"hostname",        "www.example.com", 90 * 24 * time.Hour, true, []string{"hostname mismatch"}},
+    } {
+        var requests int
+        srv := newCertServer(t, ca.issue(t, tc.host, time.Now().Add(tc.notAfter)), &requests)
+        m := NewMonitor(srv.URL)
+        m.TLS.CAFiles = []string{ca.file}
+        if err := m.Validate(); err != nil {
+            t.Fatal(err)
+        }
+        res, alerts := checkTLSAlerts(t, m)
+        if (res.Err != nil) != tc.failed || (res.Err != nil && res.Category != ErrorTLS) {
+            t.Errorf("%s: error %v (%s)", tc.name, res.Err, res.Category)
+        }
+        ok := len(alerts) == len(tc.alerts)
+        for i := 0; ok && i < len(alerts); i++ {
+            ok = strings.HasPrefix(alerts[i], tc.alerts[i])
+        }
+        if !ok {
+            t.Errorf("%s: alerts %q, want %q", tc.name, alerts, tc.alerts)
+        }
+        if tc.failed && requests > 0 {
+            t.Errorf("%s: %d requests sent to the server", tc.name, requests)
+        }
+    }
+}
+
+func TestCheckTLSVersions(t *testing.T) {
+
+    tls13 := newTLSServer(t, tls.VersionTLS13)
+    tls12 := newTLSServer(t, tls.VersionTLS12)
+
+    m := NewMonitor(tls13.URL)
+    m.TLS.Insecure = true
+    if err := m.Validate(); err != nil {
+        t.Fatal(err)
+    }
+    if _, alerts := checkTLSAlerts(t, m); len(alerts) > 0 {
+        t.Errorf("unexpected alerts %q", alerts)
+    }
+    m.URL = tls12.URL
+    if _, alerts := checkTLSAlerts(t, m); len(alerts) != 1 || alerts[0] != "TLS downgraded from TLS 1.3 to TLS 1.2" {
+        t.Errorf("alerts %q, want a downgrade", alerts)
+    }
+    if _, alerts := checkTLSAlerts(t, m); len(alerts) > 0 {
+        t.Errorf("alerts %q once the downgrade was reported", alerts)
+    }
+
+    m.TLS.MinVersion = "1.3"
+    if err := m.Validate(); err != nil {
+        t.Fatal(err)
+    }
+    if _, alerts := checkTLSAlerts(t, m); len(alerts) != 1 || alerts[0] != "negotiated TLS 1.2, expected at least TLS 1.3" {
+        t.Errorf("alerts %q, want TLS 1.3", alerts)
+    }
+}
+
+// A server that matches none of the pins is hung up on before the
+// request is sent, even if its certificate is not otherwise verified.
+func TestCheckTLSPins(t *testing.T) {
+
+    ca := newTestCA(t)
+    cert := ca.issue(t, "127.0.0.1", time.Now().Add(90 * 24 * time.Hour))
+    leaf, err := x509.ParseCertificate(cert.Certificate[0])
+    if err != nil {
+        t.Fatal(err)
+    }
+    caHash := sha256.Sum256(ca.cert.Raw)
+    other := "sha256/" + base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+
+    for _, tc := range []struct {
+        name     string
+        pins     []string
+        insecure bool
+        mismatch bool
+    }{
+        {"server's key",        []string{other, spkiPin(leaf)},              false, false},
+        {"CA certificate",      []string{"sha256:" + hex.EncodeToString(caHash[:])}, false, false},
+        {"mismatch",            []string{other},                            false, true},
+        {"insecure",            []string{spkiPin(leaf)},                    true,  false},
+        {"insecure mismatch",   []string{other},                            true,  true},
+    } {
+        var requests int
+        srv := newCertServer(t, cert, &requests)
+        m := NewMonitor(srv.URL)
+        m.TLS.Pins, m.TLS.Insecure = tc.pins, tc.insecure
+        if !tc.insecure {
+            m.TLS.CAFiles = []string{ca.file}
+        }
+        if err := m.Validate(); err != nil {
+            t.Fatal(err)
+        }
+        res, alerts := checkTLSAlerts(t, m)
+        if !tc.mismatch {
+            if res.Err != nil || len(alerts) > 0 || requests != 1 {
+                t.Errorf("%s: error %v, alerts %q, %d requests", tc.name, res.Err, alerts, requests)
+            }
+            continue
+        }
+        if Classify(res.Err) != ErrorTLS || requests > 0 {
+            t.Errorf("%s: error %v (%s), %d requests", tc.name, res.Err, Classify(res.Err), requests)
+        }
+        want := "certificate pin mismatch: the server's key is " + spkiPin(leaf)
+        if len(alerts) != 1 || alerts[0] != want {
+            t.Errorf("%s: alerts %q, want %q", tc.name, alerts, want)
+        }
+    }
+}
@@ -0,0 +1,70 @@
+package heartbeat
+
+import (
+    "net/http"
+    "net/http/httptrace"
+    "sync"
+    "time"
+)
+
+// transport is an http.RoundTripper that keeps track of the fetch
+//   request (and every redirect) and implements hooks to report HTTP
+//   tracing events.
+
+type transport struct {
+    current *http.Request
+    monitor *Monitor
+    base    http.RoundTripper  // defaults to http.DefaultTransport
+    mu      sync.Mutex         // held by the trace hooks, which may run during RoundTrip
+    hops    []Hop
+}
+
+// Wraps the base RoundTrip to keep track of the current fetch.
+func (trans *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+    trans.mu.Lock()
+    trans.current = req
+    trans.hops = append(trans.hops, Hop{URL: req.URL.String()})
+    trans.mu.Unlock()
+    base := trans.base
+    if base == nil {
+        base = http.DefaultTransport
+    }
+
+    start := time.Now()
+    resp, err := base.RoundTrip(req)
+    trans.mu.Lock()
+    defer trans.mu.Unlock()
+    hop := &trans.hops[len(trans.hops) - 1]
+    hop.Time = time.Since(start)
+    if err == nil {
+        hop.StatusCode = resp.StatusCode
+        if loc, lerr := resp.Location(); lerr == nil && isRedirected(resp) {
+            hop.Location = loc.String()
+        }
+    }
+    return resp, err
+}
+
+// Adds a DNS lookup to the current hop.
+func (trans *transport) lookup(d time.Duration) {
+
+    if n := len(trans.hops); n > 0 {
+        trans.hops[n - 1].DNS += d
+        trans.hops[n - 1].Lookups++
+    }
+}
+
+// Shows whether the connection has been used previously.
+func (trans *transport) GotConn(info httptrace.GotConnInfo) {
+
+    trans.monitor.debugf("Connection reused for '%v' ? %v - Was idle ? %v\n", trans.current.URL, info.Reused, info.WasIdle)
+}
+
+// Indicates that a "100 Continue" message has been received - which is kind of a 'Normal' error message.
+// Shouldn't show up often, probably indicates configuration issues (packet fragmentation or something).
+// It should be handled transparently, but probably worth reporting in any case.
+func (trans *transport) Got100Continue() {
+
+    trans.monitor.debugf("'100 Continue' message received\n")
+}
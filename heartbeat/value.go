@@ -0,0 +1,161 @@
+package heartbeat
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "math"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+// ValueCheck extracts a number from the response body - a stock quote,
+// for instance - and raises an alert when it crosses a threshold or
+// moves too far from the value found by the previous fetch (Stop-Loss).
+//
+// Exactly one of JSONPath, Regexp or XPath must be set.
+type ValueCheck struct {
+    JSONPath string   `json:"jsonpath"`  // as in $.quote.price
+    Regexp   string   `json:"regexp"`    // the first capture group (or the whole match)
+    XPath    string   `json:"xpath"`     // as in //span[@id='price']
+
+    Above    *float64 `json:"above"`     // alert if the value rises above this
+    Below    *float64 `json:"below"`     // alert if the value falls below this
+    Change   float64  `json:"change"`    // alert if the value moves more than this percent
+
+    steps    []jsonStep
+    re       *regexp.Regexp
+    xpath    *xpathExpr
+    prepared bool             // by Validate
+}
+
+var numberRE = regexp.MustCompile(`[-+]?[0-9][0-9,]*(\.[0-9]+)?|[-+]?\.[0-9]+`)
+
+// Validate checks (and prepares) the extraction expression and thresholds.
+func (v *ValueCheck) Validate() error {
+
+    set := 0
+    for _, s := range []string{v.JSONPath, v.Regexp, v.XPath} {
+        if s != "" {
+            set++
+        }
+    }
+    if set != 1 {
+        return fmt.Errorf("exactly one of jsonpath, regexp or xpath must be specified")
+    }
+
+    var err error
+    switch {
+    case v.JSONPath != "":
+        v.steps, err = parseJSONPath(v.JSONPath)
+    case v.Regexp != "":
+        if v.re, err = regexp.Compile(v.Regexp); err != nil {
+            err = fmt.Errorf("invalid regexp '%s': %v", v.Regexp, err)
+        }
+    case v.XPath != "":
+        v.xpath, err = parseXPath(v.XPath)
+    }
+    if err != nil {
+        return err
+    }
+
+    if v.Above != nil && v.Below != nil && *v.Below > *v.Above {
+        return fmt.Errorf("below (%v) must not be greater than above (%v)", *v.Below, *v.Above)
+    }
+    if v.Change < 0 {
+        return fmt.Errorf("change must not be negative")
+    }
+    v.prepared = true
+    return nil
+}
+
+// Extract returns the value found in a response body. A ValueCheck
+// shared by several Monitors must be validated before it is used.
+func (v *ValueCheck) Extract(body []byte) (float64, error) {
+
+    if !v.prepared {
+        if err := v.Validate(); err != nil {
+            return 0, err
+        }
+    }
+
+    switch {
+    case v.re != nil:
+        m := v.re.FindSubmatch(body)
+        if m == nil {
+            return 0, fmt.Errorf("regexp '%s' does not match", v.Regexp)
+        }
+        if len(m) > 1 {
+            return parseNumber(string(m[1]))
+        }
+        return parseNumber(string(m[0]))
+    case v.xpath != nil:
+        root, err := parseXML(body)
+        if err != nil {
+            return 0, err
+        }
+        s, err := v.xpath.eval(root)
+        if err != nil {
+            return 0, err
+        }
+        return parseNumber(s)
+    }
+
+    dec := json.NewDecoder(bytes.NewReader(body))
+    dec.UseNumber()
+    var doc interface{}
+    if err := dec.Decode(&doc); err != nil {
+        return 0, fmt.Errorf("response is not JSON: %v", err)
+    }
+    val, err := evalJSONPath(doc, v.steps)
+    if err != nil {
+        return 0, err
+    }
+    switch n := val.(type) {
+    case json.Number:
+        return n.Float64()
+    case string:
+        return parseNumber(n)
+    }
+    return 0, fmt.Errorf("%s is not a number: %v", v.JSONPath, val)
+}
+
+// check compares a new value with the thresholds and the previous value
+// (if any), returning a description of each problem found.
+func (v *ValueCheck) check(val float64, prev *float64) []string {
+
+    var problems []string
+    if v.Above != nil && val > *v.Above {
+        problems = append(problems, fmt.Sprintf("value %v is above %v", val, *v.Above))
+    }
+    if v.Below != nil && val < *v.Below {
+        problems = append(problems, fmt.Sprintf("value %v is below %v", val, *v.Below))
+    }
+    if v.Change > 0 && prev != nil && *prev != 0 {
+        pct := (val - *prev) / math.Abs(*prev) * 100.0
+        if math.Abs(pct) > v.Change {
+            problems = append(problems, fmt.Sprintf("value %v moved %+.2f%% from %v (more than %v%%)", val, pct, *prev, v.Change))
+        }
+    }
+    return problems
+}
+
+// parseNumber parses the first number found in s, ignoring any currency
+// symbols and thousands separators, as in "$1,234.50".
+func parseNumber(s string) (float64, error) {
+
+    n := numberRE.FindString(s)
+    if n == "" {
+        return 0, fmt.Errorf("no number found in '%s'", truncate(s, 40))
+    }
+    return strconv.ParseFloat(strings.Replace(n, ",", "", -1), 64)
+}
+
+func truncate(s string, n int) string {
+
+    if len(s) > n {
+        return s[:n] + "..."
+    }
+    return s
+}
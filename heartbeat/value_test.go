@@ -0,0 +1,62 @@
+package heartbeat
+
+import (
+    "strings"
+    "sync"
+    "testing"
+)
+
+func TestExtract(t *testing.T) {
+
+    for _, tc := range []struct {
+        v    ValueCheck
+        body string
+        want float64
+        err  string
+    }{
+        {ValueCheck{JSONPath: "$"},               `12.5`,                           12.5, ""},
+        {ValueCheck{JSONPath: "$.quote.price"},   `{"quote": {"price": "1,234.5"}}`, 1234.5, ""},
+        {ValueCheck{JSONPath: "$.name"},          `{"name": "acme"}`,               0, "no number found"},
+        {ValueCheck{JSONPath: "$.price"},         `<html>`,                         0, "not JSON"},
+        {ValueCheck{Regexp: `price: ([0-9.]+)`},  `last price: 42.25 USD`,          42.25, ""},
+        {ValueCheck{Regexp: `[0-9]+`},            `up 7 days`,                      7, ""},
+        {ValueCheck{Regexp: `price: ([0-9.]+)`},  `closed`,                         0, "does not match"},
+        {ValueCheck{XPath: "//span[@id='price']"}, `<p>Price <span id="price">$ 9.99</span></p>`, 9.99, ""},
+    } {
+        if err := tc.v.Validate(); err != nil {
+            t.Errorf("%+v: %v", tc.v, err)
+            continue
+        }
+        got, err := tc.v.Extract([]byte(tc.body))
+        if tc.err != "" {
+            if err == nil || !strings.Contains(err.Error(), tc.err) {
+                t.Errorf("%q: error = %v, want %q", tc.body, err, tc.err)
+            }
+            continue
+        }
+        if err != nil || got != tc.want {
+            t.Errorf("%q: got %v (%v), want %v", tc.body, got, err, tc.want)
+        }
+    }
+}
+
+// A validated ValueCheck may be shared by Monitors (as the defaults in
+// a configuration file are), even with a path of "$" and so no steps.
+func TestExtractShared(t *testing.T) {
+
+    v := &ValueCheck{JSONPath: "$"}
+    if err := v.Validate(); err != nil {
+        t.Fatal(err)
+    }
+    var wg sync.WaitGroup
+    for i := 0; i < 4; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            if got, err := v.Extract([]byte("3")); got != 3 || err != nil {
+                t.Errorf("got %v (%v)", got, err)
+            }
+        }()
+    }
+    wg.Wait()
+}
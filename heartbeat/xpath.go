@@ -0,0 +1,232 @@
+package heartbeat
+
+import (
+    "bytes"
+    "encoding/xml"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+)
+
+// xmlNode is an element of a parsed XML (or HTML) document, or a run
+// of text within one (which has no name).
+type xmlNode struct {
+    name     string
+    attr     []xml.Attr
+    children []*xmlNode     // elements and text, in document order
+    text     string
+}
+
+// string returns the text of the node, including that of its descendants.
+func (n *xmlNode) string() string {
+
+    var b strings.Builder
+    n.writeText(&b)
+    return b.String()
+}
+
+func (n *xmlNode) writeText(b *strings.Builder) {
+
+    b.WriteString(n.text)
+    for _, c := range n.children {
+        c.writeText(b)
+    }
+}
+
+// parseXML parses a document leniently, so that most HTML pages can
+// also be searched.
+func parseXML(data []byte) (*xmlNode, error) {
+
+    dec := xml.NewDecoder(bytes.NewReader(data))
+    dec.Strict    = false
+    dec.AutoClose = xml.HTMLAutoClose
+    dec.Entity    = xml.HTMLEntity
+
+    root := &xmlNode{}
+    stack := []*xmlNode{root}
+    for {
+        tok, err := dec.Token()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return nil, err
+        }
+        top := stack[len(stack) - 1]
+        switch t := tok.(type) {
+        case xml.StartElement:
+            n := &xmlNode{name: strings.ToLower(t.Name.Local), attr: t.Attr}
+            top.children = append(top.children, n)
+            stack = append(stack, n)
+        case xml.EndElement:
+            if len(stack) > 1 {
+                stack = stack[:len(stack) - 1]
+            }
+        case xml.CharData:
+            top.children = append(top.children, &xmlNode{text: string(t)})
+        }
+    }
+    return root, nil
+}
+
+// An xpathStep selects elements by name (or '*'), optionally only those
+// with a matching attribute value or at a specific (1-based) position.
+type xpathStep struct {
+    descendant bool
+    name       string
+    attr       string
+    value      string
+    position   int
+}
+
+// xpathExpr is a small subset of XPath, enough to locate a value on a
+// page, as in:
+//
+//    /quote/price
+//    //span[@id='price']
+//    //table[2]/tr[3]/td[2]
+//    //meta[@name='price']/@content
+//
+// The result is the text of the first matching element, or the value
+// of the final '@attribute' step.
+type xpathExpr struct {
+    steps []xpathStep
+    attr  string
+}
+
+func parseXPath(path string) (*xpathExpr, error) {
+
+    p := strings.TrimSpace(path)
+    if !strings.HasPrefix(p, "/") {
+        return nil, fmt.Errorf("invalid XPath '%s': must start with '/'", path)
+    }
+    x := &xpathExpr{}
+    for len(p) > 0 {
+        s := xpathStep{}
+        if strings.HasPrefix(p, "//") {
+            s.descendant = true
+            p = p[2:]
+        } else {
+            p = p[1:]
+        }
+        end := stepEnd(p)
+        step := p[:end]
+        p = p[end:]
+
+        if strings.HasPrefix(step, "@") && p == "" {
+            x.attr = step[1:]
+            break
+        }
+        if step == "text()" && p == "" {
+            break
+        }
+        if i := strings.IndexByte(step, '['); i >= 0 {
+            if !strings.HasSuffix(step, "]") {
+                return nil, fmt.Errorf("invalid XPath '%s': missing ']'", path)
+            }
+            pred := step[i + 1:len(step) - 1]
+            step = step[:i]
+            if n, err := strconv.Atoi(pred); err == nil && n > 0 {
+                s.position = n
+            } else if eq := strings.IndexByte(pred, '='); strings.HasPrefix(pred, "@") && eq > 0 {
+                s.attr  = pred[1:eq]
+                s.value = strings.Trim(pred[eq + 1:], `'"`)
+            } else {
+                return nil, fmt.Errorf("invalid XPath '%s': unsupported predicate '[%s]'", path, pred)
+            }
+        }
+        if step == "" {
+            return nil, fmt.Errorf("invalid XPath '%s': empty step", path)
+        }
+        s.name = strings.ToLower(step)
+        x.steps = append(x.steps, s)
+    }
+    return x, nil
+}
+
+// stepEnd returns the length of the first step of a path: up to the next
+// '/' that is not within a predicate (so "a[@href='/x']/b" gives 13).
+func stepEnd(p string) int {
+
+    var quote byte
+    depth := 0
+    for i := 0; i < len(p); i++ {
+        switch c := p[i]; {
+        case quote != 0:
+            if c == quote {
+                quote = 0
+            }
+        case c == '\'' || c == '"':
+            if depth > 0 {
+                quote = c
+            }
+        case c == '[':
+            depth++
+        case c == ']':
+            if depth > 0 {
+                depth--
+            }
+        case c == '/' && depth == 0:
+            return i
+        }
+    }
+    return len(p)
+}
+
+// eval returns the value selected by the expression.
+func (x *xpathExpr) eval(root *xmlNode) (string, error) {
+
+    nodes := []*xmlNode{root}
+    for _, s := range x.steps {
+        var next []*xmlNode
+        for _, n := range nodes {
+            next = append(next, s.match(n)...)
+        }
+        if len(next) == 0 {
+            return "", fmt.Errorf("no element matches '%s'", s.name)
+        }
+        nodes = next
+    }
+    n := nodes[0]
+    if x.attr == "" {
+        return strings.TrimSpace(n.string()), nil
+    }
+    for _, a := range n.attr {
+        if strings.EqualFold(a.Name.Local, x.attr) {
+            return a.Value, nil
+        }
+    }
+    return "", fmt.Errorf("element '%s' has no attribute '%s'", n.name, x.attr)
+}
+
+// match returns the children (or descendants) of n selected by the step.
+func (s xpathStep) match(n *xmlNode) []*xmlNode {
+
+    var found []*xmlNode
+    count := 0
+    for _, c := range n.children {
+        if c.name != "" && (s.name == "*" || c.name == s.name) {
+            if s.attr == "" || c.hasAttr(s.attr, s.value) {
+                count++
+                if s.position == 0 || s.position == count {
+                    found = append(found, c)
+                }
+            }
+        }
+        if s.descendant {
+            found = append(found, s.match(c)...)
+        }
+    }
+    return found
+}
+
+func (n *xmlNode) hasAttr(name, value string) bool {
+
+    for _, a := range n.attr {
+        if strings.EqualFold(a.Name.Local, name) && a.Value == value {
+            return true
+        }
+    }
+    return false
+}
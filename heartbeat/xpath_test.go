@@ -0,0 +1,87 @@
+package heartbeat
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestParseXPath(t *testing.T) {
+
+    for _, tc := range []struct {
+        path  string
+        steps []xpathStep
+        attr  string
+        err   string
+    }{
+        {"/quote/price",            []xpathStep{{name: "quote"}, {name: "price"}}, "", ""},
+        {"//span[@id='price']",     []xpathStep{{descendant: true, name: "span", attr: "id", value: "price"}}, "", ""},
+        {"//table[2]/TR[3]/text()", []xpathStep{{descendant: true, name: "table", position: 2}, {name: "tr", position: 3}}, "", ""},
+        {"//meta[@name='price']/@content", []xpathStep{{descendant: true, name: "meta", attr: "name", value: "price"}}, "content", ""},
+        {"//a[@href='/quote/acme']/span", []xpathStep{{descendant: true, name: "a", attr: "href", value: "/quote/acme"}, {name: "span"}}, "", ""},
+        {"quote/price",             nil, "", "must start with '/'"},
+        {"/quote//",                nil, "", "empty step"},
+        {"//span[@id='price'",      nil, "", "missing ']'"},
+        {"//span[last()]",          nil, "", "unsupported predicate '[last()]'"},
+    } {
+        x, err := parseXPath(tc.path)
+        if tc.err != "" {
+            if err == nil || !strings.Contains(err.Error(), tc.err) {
+                t.Errorf("parseXPath(%q) error = %v, want %q", tc.path, err, tc.err)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("parseXPath(%q): %v", tc.path, err)
+            continue
+        }
+        if !reflect.DeepEqual(x.steps, tc.steps) || x.attr != tc.attr {
+            t.Errorf("parseXPath(%q) = %+v @%q, want %+v @%q", tc.path, x.steps, x.attr, tc.steps, tc.attr)
+        }
+    }
+}
+
+func TestEvalXPath(t *testing.T) {
+
+    const page = `<html><head><meta name="price" content="12.50"></head>
+<body>
+  <p>Price: <b>12</b>.<i>50</i> USD</p>
+  <a href="/quote/acme"><span>ACME</span></a>
+  <table><tr><td>a</td><td>b</td></tr><tr><td>c</td><td>d &amp; e</td></tr></table>
+</body></html>`
+
+    root, err := parseXML([]byte(page))
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, tc := range []struct {
+        path string
+        want string
+        err  string
+    }{
+        {"//p",                             "Price: 12.50 USD", ""},
+        {"/html/body/p/b",                  "12",               ""},
+        {"//meta[@name='price']/@content",  "12.50",            ""},
+        {"//a[@href='/quote/acme']/span",   "ACME",             ""},
+        {"//tr[2]/td[2]",                   "d & e",            ""},
+        {"//table/*[1]/td[2]",              "b",                ""},
+        {"//div",                           "",                 "no element matches 'div'"},
+        {"//a/@title",                      "",                 "element 'a' has no attribute 'title'"},
+    } {
+        x, err := parseXPath(tc.path)
+        if err != nil {
+            t.Errorf("parseXPath(%q): %v", tc.path, err)
+            continue
+        }
+        got, err := x.eval(root)
+        if tc.err != "" {
+            if err == nil || !strings.Contains(err.Error(), tc.err) {
+                t.Errorf("%s: error = %v, want %q", tc.path, err, tc.err)
+            }
+            continue
+        }
+        if err != nil || got != tc.want {
+            t.Errorf("%s = %q (%v), want %q", tc.path, got, err, tc.want)
+        }
+    }
+}
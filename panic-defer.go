@@ -1,3 +1,5 @@
+//go:build ignore
+
 // Some experiments & simple tests for 'panic' and 'defer'
 //
 // Go's panic/recover seems similiar to Java's try/catch/finally